@@ -0,0 +1,116 @@
+package pyexec
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// capture collects one output stream of a script. It keeps at most limit
+// bytes (if limit > 0) and moves the data to a temporary file once more
+// than spillAt bytes (if spillAt > 0) have arrived. Write never fails, so
+// the copy loop keeps draining the pipe and the script never blocks on it.
+type capture struct {
+	limit     int64
+	spillAt   int64
+	spillDir  string
+	mem       bytes.Buffer
+	file      *os.File
+	size      int64
+	truncated bool
+	// tail holds the last tailSize bytes written when the output may not
+	// all end up in memory, including those beyond the limit.
+	tail []byte
+}
+
+// tailSize is how much of the end of a stream a capture keeps even when
+// the stream is truncated or spilled, enough for the error message Python
+// prints last.
+const tailSize = 4096
+
+func newCapture(c *config, stream Stream) *capture {
+	return &capture{
+		limit:    c.outputLimits[stream],
+		spillAt:  c.spillAt,
+		spillDir: c.spillDir,
+	}
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	n := len(p)
+	if c.limit > 0 || c.spillAt > 0 {
+		c.keepTail(p)
+	}
+	if c.limit > 0 && c.size+int64(len(p)) > c.limit {
+		p = p[:max(0, c.limit-c.size)]
+		c.truncated = true
+	}
+	if len(p) == 0 {
+		return n, nil
+	}
+	if c.file == nil && c.spillAt > 0 && int64(c.mem.Len()+len(p)) > c.spillAt {
+		c.spill()
+	}
+	if c.file != nil {
+		if _, err := c.file.Write(p); err != nil {
+			GetZlog().Warn().Err(err).Str("file", c.file.Name()).Msg("Failed to write spilled output, truncating")
+			c.truncated = true
+			c.limit = c.size // drop everything from here on
+			return n, nil
+		}
+	} else {
+		c.mem.Write(p)
+	}
+	c.size += int64(len(p))
+	return n, nil
+}
+
+// keepTail appends p to the tail, dropping what falls out of it.
+func (c *capture) keepTail(p []byte) {
+	if len(p) >= tailSize {
+		c.tail = append(c.tail[:0], p[len(p)-tailSize:]...)
+		return
+	}
+	if drop := len(c.tail) + len(p) - tailSize; drop > 0 {
+		c.tail = append(c.tail[:0], c.tail[drop:]...)
+	}
+	c.tail = append(c.tail, p...)
+}
+
+// spill moves what has been captured so far to a temporary file. If the
+// file cannot be created, capturing continues in memory.
+func (c *capture) spill() {
+	f, err := os.CreateTemp(c.spillDir, "pyexec-output-*")
+	if err != nil {
+		GetZlog().Warn().Err(err).Msg("Failed to create spill file, keeping output in memory")
+		c.spillAt = 0
+		return
+	}
+	if _, err := f.Write(c.mem.Bytes()); err != nil {
+		GetZlog().Warn().Err(err).Msg("Failed to write spill file, keeping output in memory")
+		f.Close()
+		os.Remove(f.Name())
+		c.spillAt = 0
+		return
+	}
+	c.mem = bytes.Buffer{}
+	c.file = f
+}
+
+// finish ends the capture and returns the data held in memory, or the
+// path of the spill file if the output was spilled.
+func (c *capture) finish() (data []byte, path string) {
+	if c.file == nil {
+		return c.mem.Bytes(), ""
+	}
+	c.file.Close()
+	return nil, c.file.Name()
+}
+
+// openCaptured returns a reader over output held in memory or spilled to path.
+func openCaptured(data []byte, path string) (io.ReadCloser, error) {
+	if path != "" {
+		return os.Open(path)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,62 @@
+package pyexec
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOutputCapture(t *testing.T) {
+	script := writeScript(t, "noisy.py", "import sys\nsys.stdout.write('o' * 100000)\nsys.stderr.write('e' * 5000)\n")
+
+	t.Run("Limit", func(t *testing.T) {
+		for _, run := range []func(context.Context, string, []Arg, ...Option) (*Result, error){
+			NewExecutor().Run,
+			NewExecutor(WithStdout(nil), WithStderr(nil)).Stream,
+		} {
+			res, err := run(context.Background(), script, nil, WithOutputLimit(1000, 0))
+			if err != nil {
+				t.Fatalf("Execution failed: %v", err)
+			}
+			if len(res.Stdout) != 1000 || !res.StdoutTruncated {
+				t.Errorf("Expected 1000 truncated stdout bytes, got %d (truncated=%v)", len(res.Stdout), res.StdoutTruncated)
+			}
+			if len(res.Stderr) != 5000 || res.StderrTruncated {
+				t.Errorf("Expected 5000 untruncated stderr bytes, got %d (truncated=%v)", len(res.Stderr), res.StderrTruncated)
+			}
+		}
+	})
+
+	t.Run("Spill", func(t *testing.T) {
+		spillDir := t.TempDir()
+		res, err := NewExecutor(WithSpill(10000, spillDir)).Run(context.Background(), script, nil)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if res.Stdout != nil {
+			t.Errorf("Expected spilled stdout to be nil in memory, got %d bytes", len(res.Stdout))
+		}
+		if string(res.Stderr) != strings.Repeat("e", 5000) {
+			t.Errorf("Expected stderr under the threshold to stay in memory")
+		}
+
+		r, err := res.OpenStdout()
+		if err != nil {
+			t.Fatalf("OpenStdout failed: %v", err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || string(data) != strings.Repeat("o", 100000) {
+			t.Errorf("Spilled stdout is incomplete: %d bytes, err %v", len(data), err)
+		}
+
+		if err := res.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if entries, _ := os.ReadDir(spillDir); len(entries) != 0 {
+			t.Errorf("Expected spill files to be removed, found %d", len(entries))
+		}
+	})
+}
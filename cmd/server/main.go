@@ -9,11 +9,43 @@ import (
 	"github.com/liuzl/pyexec"
 )
 
+var (
+	cpuLimit    = flag.Duration("cpu-limit", 0, "CPU time limit per execution (0 for none)")
+	memoryLimit = flag.Int64("memory-limit", 0, "address space limit per execution in bytes (0 for none)")
+	openFiles   = flag.Int("max-open-files", 0, "open file descriptor limit per execution (0 for none)")
+	processes   = flag.Int("max-processes", 0, "process limit for the scripts' user (0 for none)")
+	fileSize    = flag.Int64("max-file-size", 0, "size limit in bytes for files written by a script (0 for none)")
+	maxRunning  = flag.Int("max-concurrent", 0, "maximum number of scripts running at once; others wait (0 for no limit)")
+	runAs       = flag.String("user", "", "user name or uid to run scripts as (default: the server's user)")
+)
+
 func main() {
 	flag.Parse()
-	// Register the handler from pyexec package
+	opts := []pyexec.Option{
+		pyexec.WithBackend(pyexec.BackendUV),
+		pyexec.WithLimits(pyexec.Limits{
+			CPUTime:   *cpuLimit,
+			Memory:    *memoryLimit,
+			OpenFiles: *openFiles,
+			Processes: *processes,
+			FileSize:  *fileSize,
+		}),
+	}
+	if *maxRunning > 0 {
+		opts = append(opts, pyexec.WithLimiter(pyexec.NewLimiter(pyexec.WithMaxConcurrent(*maxRunning))))
+	}
+	if *runAs != "" {
+		cred, err := pyexec.LookupCredential(*runAs)
+		if err != nil {
+			log.Fatalf("Invalid -user: %v\n", err)
+		}
+		opts = append(opts, pyexec.WithCredential(cred))
+		fmt.Printf("Running scripts as uid %d, gid %d\n", cred.UID, cred.GID)
+	}
+	exe := pyexec.NewExecutor(opts...)
+	// Register the executor's handler
 	// It will handle requests like /execute/hello.py
-	http.HandleFunc("/execute/", pyexec.HandlePythonExecutionRequestWithUV) // Note the trailing slash
+	http.HandleFunc("/execute/", exe.HandleExecutionRequest) // Note the trailing slash
 
 	port := "8080"
 	fmt.Printf("Starting server on port %s...\n", port)
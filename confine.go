@@ -0,0 +1,140 @@
+package pyexec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+)
+
+// FSConfinement restricts which files a script can access, using Landlock
+// (Linux 5.13 or later). A confined script can read its own directory,
+// its interpreter with its standard library and site-packages, and the
+// system files listed in systemReadPaths, including /proc and /sys; it can
+// write only to a temporary directory created for the execution, passed to
+// it as TMPDIR and removed afterwards, to its Workspace, if it has one, and
+// to the shared memory in /dev/shm. Everything else is denied, with
+// EACCES. Restrictions are inherited by every process the script starts.
+type FSConfinement struct {
+	// Read lists further files and directories the script may read.
+	Read []string
+	// Write lists further files and directories the script may read and
+	// write.
+	Write []string
+	// Fallback decides what happens where Landlock is not available.
+	Fallback ConfinementFallback
+}
+
+// ConfinementFallback is the policy for running confined scripts where
+// Landlock is not available.
+type ConfinementFallback int
+
+const (
+	// FailClosed refuses to run the script, returning
+	// ErrConfinementUnavailable. It is the default.
+	FailClosed ConfinementFallback = iota
+	// RunUnconfined runs the script without confinement and logs a
+	// warning.
+	RunUnconfined
+)
+
+// systemReadPaths are readable by every confined script, since the dynamic
+// loader, Python and common libraries need them; e.g. os.cpu_count and
+// OpenBLAS read the CPU topology from /proc and /sys. Landlock still keeps
+// the script from inspecting processes outside its sandbox, such as the
+// environment of the current process, through /proc.
+var systemReadPaths = []string{
+	"/usr", "/lib", "/lib64", "/bin",
+	"/etc/ld.so.cache", "/etc/localtime", "/etc/passwd", "/etc/group",
+	"/etc/nsswitch.conf", "/etc/hosts", "/etc/host.conf", "/etc/resolv.conf",
+	"/etc/ssl", "/etc/pki", "/etc/ca-certificates",
+	"/dev/urandom", "/dev/random", "/dev/zero",
+	"/proc", "/sys",
+}
+
+// systemWritePaths are writable by every confined script. multiprocessing
+// creates its semaphores in /dev/shm.
+var systemWritePaths = []string{"/dev/null", "/dev/shm"}
+
+// landlockSettings mirrors the "landlock" settings read by launcher.py,
+// which adds the launching interpreter's own directories to Read.
+type landlockSettings struct {
+	ABI   int      `json:"abi"`
+	Read  []string `json:"read"`
+	Write []string `json:"write"`
+}
+
+// landlockSettings returns the rules confining a script in scriptDir, or
+// nil if it runs unconfined.
+func (c *config) landlockSettings(scriptDir string) (*landlockSettings, error) {
+	if c.confinement == nil {
+		return nil, nil
+	}
+	abi := landlockABI()
+	if abi < 1 {
+		if c.confinement.Fallback == FailClosed {
+			return nil, fmt.Errorf("%w: Landlock is not supported by this kernel", ErrConfinementUnavailable)
+		}
+		GetZlog().Warn().Msg("Landlock is not supported by this kernel, running script unconfined")
+		return nil, nil
+	}
+	s := &landlockSettings{
+		ABI:   abi,
+		Read:  slices.Concat(c.confinement.Read, systemReadPaths),
+		Write: slices.Concat(c.confinement.Write, systemWritePaths),
+	}
+	if scriptDir != "" {
+		s.Read = append(s.Read, scriptDir)
+	}
+	if c.execDir != nil {
+		s.Write = append(s.Write, c.execDir.path)
+	}
+	var helpers []*helperDir
+	if c.shimHelper != nil {
+		helpers = append(helpers, c.shimHelper)
+	}
+	if c.resultPipe {
+		helpers = append(helpers, resultHelper)
+	}
+	for _, helper := range helpers {
+		dir, err := helper.dir()
+		if err != nil {
+			return nil, err
+		}
+		s.Read = append(s.Read, dir)
+	}
+	if c.backend == BackendUV {
+		read, write := uvPaths()
+		s.Read = append(s.Read, read...)
+		s.Write = append(s.Write, write...)
+	}
+	return s, nil
+}
+
+// uvPaths returns what `uv run` needs beyond the script's project: its own
+// executable and managed Python installations to read, and its cache to
+// write, following uv's environment variables and XDG defaults.
+func uvPaths() (read, write []string) {
+	if path, err := exec.LookPath("uv"); err == nil {
+		read = append(read, path)
+	}
+	home, _ := os.UserHomeDir()
+	xdg := func(name, fallback string) string {
+		if dir := os.Getenv(name); dir != "" {
+			return dir
+		}
+		return filepath.Join(home, fallback)
+	}
+	if dir := os.Getenv("UV_PYTHON_INSTALL_DIR"); dir != "" {
+		read = append(read, dir)
+	} else {
+		read = append(read, filepath.Join(xdg("XDG_DATA_HOME", ".local/share"), "uv"))
+	}
+	if dir := os.Getenv("UV_CACHE_DIR"); dir != "" {
+		write = append(write, dir)
+	} else {
+		write = append(write, filepath.Join(xdg("XDG_CACHE_HOME", ".cache"), "uv"))
+	}
+	return read, write
+}
@@ -0,0 +1,98 @@
+package pyexec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFSConfinement(t *testing.T) {
+	if landlockABI() < 1 {
+		t.Skip("Landlock is not supported by this kernel")
+	}
+	secretDir := t.TempDir()
+	secret := filepath.Join(secretDir, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", secret, err)
+	}
+	script := writeScript(t, "confined.py", `import multiprocessing, os, sys, tempfile
+here = os.path.dirname(os.path.abspath(__file__))
+print(sorted(os.listdir(here)))
+with tempfile.NamedTemporaryFile(dir=os.environ["TMPDIR"]) as f:
+    f.write(b"scratch")
+print("tmp ok")
+open("/proc/self/status").close()
+os.listdir("/sys/devices/system/cpu")
+multiprocessing.Lock()
+print("system ok")
+for path, mode in ((sys.argv[1], "r"), (os.path.join(here, "out.txt"), "w")):
+    try:
+        open(path, mode).close()
+        print("allowed", os.path.basename(path))
+    except PermissionError:
+        print("denied", os.path.basename(path))
+`)
+	args := []Arg{{Key: secret}}
+	exe := NewExecutor(WithFSConfinement(FSConfinement{}))
+
+	res, err := exe.Run(context.Background(), script, args)
+	if err != nil {
+		t.Fatalf("Run failed: %v (stderr %q)", err, res.Stderr)
+	}
+	want := "['confined.py']\ntmp ok\nsystem ok\ndenied secret.txt\ndenied out.txt\n"
+	if string(res.Stdout) != want {
+		t.Errorf("Expected stdout %q, got %q", want, res.Stdout)
+	}
+
+	res, err = exe.Run(context.Background(), script, args, WithFSConfinement(FSConfinement{Read: []string{secretDir}}))
+	if err != nil {
+		t.Fatalf("Run failed: %v (stderr %q)", err, res.Stderr)
+	}
+	if !strings.Contains(string(res.Stdout), "allowed secret.txt") {
+		t.Errorf("Expected the extra read path to be allowed, got %q", res.Stdout)
+	}
+}
+
+func TestFSConfinementUnavailable(t *testing.T) {
+	if landlockABI() >= 1 {
+		t.Skip("Landlock is supported by this kernel")
+	}
+	script := writeScript(t, "hello.py", "print('hello')\n")
+	_, err := NewExecutor(WithFSConfinement(FSConfinement{})).Run(context.Background(), script, nil)
+	if !errors.Is(err, ErrConfinementUnavailable) {
+		t.Errorf("Expected ErrConfinementUnavailable, got %v", err)
+	}
+	res, err := NewExecutor(WithFSConfinement(FSConfinement{Fallback: RunUnconfined})).Run(context.Background(), script, nil)
+	if err != nil || string(res.Stdout) != "hello\n" {
+		t.Errorf("Expected the script to run unconfined, got %q, %v", res.Stdout, err)
+	}
+}
+
+func TestFSConfinementShims(t *testing.T) {
+	if landlockABI() < 1 {
+		t.Skip("Landlock is not supported by this kernel")
+	}
+	ctx := context.Background()
+	script := writeScript(t, "confined_pooled.py", poolTestScript)
+	exe := NewExecutor(WithFSConfinement(FSConfinement{Read: []string{filepath.Dir(script)}}))
+
+	p, err := exe.NewPool(ctx, script)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer p.Close()
+	callPool(t, p)
+
+	fs, err := exe.NewForkServer(ctx, "json")
+	if err != nil {
+		t.Fatalf("NewForkServer failed: %v", err)
+	}
+	defer fs.Close()
+	res, err := fs.Run(ctx, script, nil)
+	if err == nil || !strings.Contains(string(res.Stderr), "must not run in a pool worker") {
+		t.Errorf("Expected the script to run as __main__, got %v (stderr %q)", err, res.Stderr)
+	}
+}
@@ -0,0 +1,69 @@
+package pyexec
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"strconv"
+)
+
+// Credential is the user and groups a script runs as; see WithCredential.
+type Credential struct {
+	UID uint32
+	GID uint32
+	// Groups are the supplementary group ids. When empty, the script has
+	// no supplementary groups.
+	Groups []uint32
+}
+
+// LookupCredential returns the credential of the user with the given name
+// or numeric id, with the user's primary group and the groups they are a
+// member of.
+func LookupCredential(name string) (Credential, error) {
+	u, err := user.Lookup(name)
+	var unknown user.UnknownUserError
+	if errors.As(err, &unknown) {
+		if _, numErr := strconv.ParseUint(name, 10, 32); numErr == nil {
+			u, err = user.LookupId(name)
+		}
+	}
+	if err != nil {
+		return Credential{}, fmt.Errorf("failed to look up user %q: %w", name, err)
+	}
+	var cred Credential
+	if cred.UID, err = parseID(u.Uid); err != nil {
+		return Credential{}, fmt.Errorf("user %q has unsupported uid %q: %w", name, u.Uid, err)
+	}
+	if cred.GID, err = parseID(u.Gid); err != nil {
+		return Credential{}, fmt.Errorf("user %q has unsupported gid %q: %w", name, u.Gid, err)
+	}
+	groups, err := u.GroupIds()
+	if err != nil {
+		return Credential{}, fmt.Errorf("failed to look up groups of user %q: %w", name, err)
+	}
+	for _, g := range groups {
+		gid, err := parseID(g)
+		if err != nil {
+			return Credential{}, fmt.Errorf("user %q has unsupported group id %q: %w", name, g, err)
+		}
+		cred.Groups = append(cred.Groups, gid)
+	}
+	return cred, nil
+}
+
+// logStart logs that cmd is being started, with the user and group it runs
+// as if they are not those of the current process.
+func logStart(c *config, cmd *exec.Cmd, msg string) {
+	ev := GetZlog().Info().Str("cmd", cmd.String())
+	if c.credential != nil {
+		ev = ev.Uint32("uid", c.credential.UID).Uint32("gid", c.credential.GID)
+	}
+	ev.Msg(msg)
+}
+
+// parseID parses a numeric Unix user or group id.
+func parseID(s string) (uint32, error) {
+	id, err := strconv.ParseUint(s, 10, 32)
+	return uint32(id), err
+}
@@ -0,0 +1,80 @@
+package pyexec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	// ErrScriptNotFound is returned when a script cannot be located by any
+	// of the script discovery rules.
+	ErrScriptNotFound = errors.New("script not found")
+	// ErrInterpreterNotFound is returned when the Python interpreter, or uv
+	// for BackendUV, is not available.
+	ErrInterpreterNotFound = errors.New("interpreter not found")
+	// ErrTimeout is returned when a script is stopped because its deadline,
+	// from WithTimeout or the caller's context, expired. The error also
+	// matches context.DeadlineExceeded.
+	ErrTimeout = errors.New("execution timed out")
+	// ErrIdleTimeout is returned when a script is stopped because it
+	// produced no output for longer than its WithIdleTimeout.
+	ErrIdleTimeout = errors.New("execution idle timeout")
+	// ErrConfinementUnavailable is returned when a script must be confined
+	// with WithFSConfinement but the platform does not support it.
+	ErrConfinementUnavailable = errors.New("filesystem confinement unavailable")
+)
+
+// ScriptExitError is returned when a script exits with a non-zero status.
+type ScriptExitError struct {
+	Script   string
+	Dir      string
+	ExitCode int
+	Reason   TerminationReason
+	Stdout   []byte
+	Stderr   []byte
+	// Err is the underlying error, usually an *exec.ExitError.
+	Err error
+}
+
+func (e *ScriptExitError) Error() string {
+	return scriptErrorMessage(e.Script, e.Dir, fmt.Sprintf("exit status %d", e.ExitCode), e.Reason, e.Stdout, e.Stderr)
+}
+
+func (e *ScriptExitError) Unwrap() error { return e.Err }
+
+// ScriptSignalError is returned when a script is terminated by a signal,
+// e.g. from the OOM killer or one sent with Execution.Signal or Kill. A
+// script stopped because its context is done or its timeout expires
+// reports the context's error instead.
+type ScriptSignalError struct {
+	Script string
+	Dir    string
+	Signal os.Signal
+	Reason TerminationReason
+	Stdout []byte
+	Stderr []byte
+	// Err is the underlying error, usually an *exec.ExitError.
+	Err error
+}
+
+func (e *ScriptSignalError) Error() string {
+	return scriptErrorMessage(e.Script, e.Dir, fmt.Sprintf("signal: %v", e.Signal), e.Reason, e.Stdout, e.Stderr)
+}
+
+func (e *ScriptSignalError) Unwrap() error { return e.Err }
+
+// scriptErrorMessage formats a script failure with its captured output.
+func scriptErrorMessage(script, dir, status string, reason TerminationReason, stdout, stderr []byte) string {
+	msg := fmt.Sprintf("python script '%s' (in dir %s) execution failed: %s", script, dir, status)
+	if reason != ReasonNone {
+		msg += fmt.Sprintf(" (%s)", reason)
+	}
+	if len(stderr) > 0 {
+		msg += fmt.Sprintf("\nstderr: %s", string(stderr))
+	}
+	if len(stdout) > 0 {
+		msg += fmt.Sprintf("\nstdout: %s", string(stdout))
+	}
+	return msg
+}
@@ -0,0 +1,373 @@
+package pyexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Execution is a handle to a running script, returned by Start.
+// Its methods are safe for concurrent use.
+type Execution struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	done  chan struct{}
+	// res and err are set before done is closed.
+	res *Result
+	err error
+
+	// mu guards stage and exited, so no signal is sent once the process
+	// has been waited for.
+	mu     sync.Mutex
+	stage  StopStage
+	exited bool
+}
+
+// Start launches scriptName with a default Executor configured by opts and
+// returns without waiting for it to exit.
+func Start(scriptName string, args []Arg, opts ...Option) (*Execution, error) {
+	return NewExecutor(opts...).Start(context.Background(), scriptName, args)
+}
+
+// Start launches the script and returns a handle to it without waiting for
+// it to exit. The script is killed when ctx is done or the timeout
+// expires. Output is captured as by Run; if a LineHandler is configured,
+// lines are also delivered to it while the script runs.
+// Options override the executor's configuration for this call only.
+func (e *Executor) Start(ctx context.Context, scriptName string, args []Arg, opts ...Option) (*Execution, error) {
+	c := e.configFor(scriptName, opts)
+	var sink *lineSink
+	if c.lineHandler != nil {
+		sink = newLineSink(&c)
+	}
+	return c.start(ctx, scriptName, args, sink)
+}
+
+// start launches the script. Output is captured and, if sink is not nil,
+// split into lines for it as well.
+func (c *config) start(ctx context.Context, scriptName string, args []Arg, sink *lineSink) (*Execution, error) {
+	release, err := c.limiter.acquire(ctx, scriptName)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.withTimeout(ctx)
+	ctx, idle := c.withIdleTimeout(ctx)
+	if c.workspace != nil || c.confinement != nil {
+		d, err := c.newExecDir()
+		if err != nil {
+			cancel()
+			release()
+			return nil, err
+		}
+		c.execDir = d
+		if c.workspace != nil {
+			c.dir = d.path
+		}
+	}
+	// abort releases what was set up when the script cannot be started.
+	abort := func() {
+		cancel()
+		c.execDir.remove()
+		release()
+	}
+	cmd, err := c.command(ctx, scriptName, args)
+	if err != nil {
+		abort()
+		return nil, err
+	}
+	x := &Execution{cmd: cmd, done: make(chan struct{})}
+	cmd.Cancel = func() error {
+		go x.stop(c.interruptGrace, c.terminateGrace)
+		return nil
+	}
+	if c.stdinPipe {
+		if x.stdin, err = cmd.StdinPipe(); err != nil {
+			abort()
+			return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
+		}
+	}
+
+	// The output goes through pipes of our own rather than exec's copying,
+	// so Wait returns as soon as the script exits even if processes it
+	// left behind still hold the pipes open; those are killed below.
+	var readers, writers [2]*os.File
+	closeAll := func(files [2]*os.File) {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}
+	ttyStdin := false
+	if c.pty != nil {
+		// Both streams go to the terminal and are read from its master end
+		// as stdout.
+		rows, cols := c.pty.size()
+		if readers[Stdout], writers[Stdout], err = openPTY(rows, cols); err != nil {
+			abort()
+			return nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+		}
+		cmd.Stderr = writers[Stdout]
+		if cmd.Stdin == nil {
+			cmd.Stdin = writers[Stdout]
+			ttyStdin = true
+		}
+		setControllingTerminal(cmd)
+	} else {
+		for _, stream := range []Stream{Stdout, Stderr} {
+			if readers[stream], writers[stream], err = os.Pipe(); err != nil {
+				closeAll(readers)
+				closeAll(writers)
+				abort()
+				return nil, fmt.Errorf("failed to create %s pipe: %w", stream, err)
+			}
+		}
+		cmd.Stderr = writers[Stderr]
+	}
+	cmd.Stdout = writers[Stdout]
+
+	// The result pipe is read like the output, and the script may report
+	// its result at any time before it exits.
+	var resultR, resultW *os.File
+	if c.resultPipe {
+		var env []string
+		if resultR, resultW, err = os.Pipe(); err == nil {
+			// ExtraFiles[i] becomes descriptor 3+i in the script.
+			cmd.ExtraFiles = append(cmd.ExtraFiles, resultW)
+			env, err = resultPipeEnv(cmd.Env, 2+len(cmd.ExtraFiles))
+			cmd.Env = append(cmd.Env, env...)
+		}
+		if err != nil {
+			closeAll(readers)
+			closeAll(writers)
+			closeAll([2]*os.File{resultR, resultW})
+			abort()
+			return nil, fmt.Errorf("failed to set up result pipe: %w", err)
+		}
+	}
+
+	logStart(c, cmd, "Executing command")
+	start := time.Now()
+	err = cmd.Start()
+	closeAll(writers)
+	if resultW != nil {
+		resultW.Close()
+	}
+	if err != nil {
+		closeAll(readers)
+		if resultR != nil {
+			resultR.Close()
+		}
+		abort()
+		return nil, fmt.Errorf("failed to start python script '%s' in dir '%s': %w", scriptName, cmd.Dir, err)
+	}
+	if ttyStdin {
+		// There is no input, so the script reads end of file, as it would
+		// from an empty stdin.
+		if _, err := readers[Stdout].WriteString(eofCharacter); err != nil {
+			GetZlog().Warn().Err(err).Str("script", scriptName).Msg("Failed to write end of input to terminal")
+		}
+	}
+	if idle != nil {
+		go idle.watch(x.done)
+	}
+	var sampler *usageSampler
+	if c.sampleInterval > 0 {
+		sampler = sampleUsage(cmd.Process.Pid, start, c.sampleInterval)
+	}
+	var tracker *descendantTracker
+	if c.subreaper {
+		tracker = trackDescendants(cmd.Process.Pid)
+	}
+
+	// Capture and line splitting happen in the same copy loop, so the pipes
+	// are always drained and the captured output is complete no matter how
+	// long the lines are.
+	stdout, stderr := newCapture(c, Stdout), newCapture(c, Stderr)
+	var wg sync.WaitGroup
+	pump := func(stream Stream, pipe io.Reader, capture io.Writer) {
+		defer wg.Done()
+		var w io.Writer = capture
+		if sink != nil {
+			lines := sink.writer(stream, c.maxLine)
+			defer lines.Flush()
+			w = io.MultiWriter(capture, lines)
+		}
+		if c.pty != nil && c.pty.StripANSI {
+			w = newANSIStripper(w)
+		}
+		if idle != nil {
+			w = io.MultiWriter(w, idle)
+		}
+		if _, err := io.Copy(w, pipe); err != nil && !errors.Is(err, os.ErrClosed) {
+			GetZlog().Warn().Err(err).Str("script", scriptName).Str("stream", stream.String()).Msg("Error reading script output")
+		}
+	}
+	var payload []byte
+	if resultR != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer resultR.Close()
+			var err error
+			if payload, err = io.ReadAll(resultR); err != nil && !errors.Is(err, os.ErrClosed) {
+				GetZlog().Warn().Err(err).Str("script", scriptName).Msg("Error reading script result")
+			}
+		}()
+	}
+	if c.pty != nil {
+		wg.Add(1)
+		go pump(Stdout, ptyReader{readers[Stdout]}, stdout)
+	} else {
+		wg.Add(2)
+		go pump(Stdout, readers[Stdout], stdout)
+		go pump(Stderr, readers[Stderr], stderr)
+	}
+
+	go func() {
+		defer close(x.done)
+		defer release()
+		defer cancel()
+		err := cmd.Wait()
+		usage := sampler.finish()
+		x.mu.Lock()
+		x.exited = true
+		stage := x.stage
+		x.mu.Unlock()
+
+		killProcessGroup(cmd)
+		if n := tracker.finish(); n > 0 {
+			GetZlog().Info().Str("script", scriptName).Int("count", n).Msg("Killed processes left behind by script")
+		}
+		// Processes that left the process group, e.g. with setsid, may
+		// still hold the pipes open. Once ctx is done they are not waited
+		// for longer than outputGrace; closing the pipes ends the pumps.
+		drained := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			select {
+			case <-drained:
+			case <-time.After(outputGrace):
+				closeAll(readers)
+				closeAll([2]*os.File{resultR})
+				<-drained
+			}
+		}
+		closeAll(readers)
+
+		x.res = newResult(cmd, start, stdout, stderr)
+		x.res.StopStage = stage
+		x.res.Usage = usage
+		x.res.Payload = payload
+		x.res.Reason = c.terminationReason(ctx, x.res)
+		if err != nil {
+			x.err = executionError(ctx, scriptName, cmd.Dir, x.res, err)
+		}
+		c.finishExecDir(scriptName, x.res, x.err)
+	}()
+	return x, nil
+}
+
+// outputGrace is how long the output of a canceled or timed out script is
+// still read after it has been stopped, for processes that escaped its
+// process group.
+const outputGrace = 500 * time.Millisecond
+
+// Pid returns the process id of the process started for the script: its
+// interpreter, uv for BackendUV, or, with WithSubreaper, the launcher that
+// supervises it, the script then being a child of that process. Launchers
+// that only apply settings, such as WithLimits, replace themselves with the
+// interpreter and keep the pid. On Linux, Signal reaches the script in
+// every case, through its process group.
+func (x *Execution) Pid() int {
+	return x.cmd.Process.Pid
+}
+
+// Stdin returns the writer connected to the script's standard input when
+// the execution was started with WithStdinPipe, and nil otherwise.
+// Close it to signal end of input.
+func (x *Execution) Stdin() io.WriteCloser {
+	return x.stdin
+}
+
+// Done returns a channel that is closed once the script has exited and its
+// Result is available from Wait.
+func (x *Execution) Done() <-chan struct{} {
+	return x.done
+}
+
+// Wait waits for the script to exit and returns its Result and error, as
+// Run does. It can be called any number of times.
+func (x *Execution) Wait() (*Result, error) {
+	<-x.done
+	return x.res, x.err
+}
+
+// Signal sends sig to the script. On Linux it is delivered to the script's
+// whole process group. It returns os.ErrProcessDone if the script has
+// already exited.
+func (x *Execution) Signal(sig os.Signal) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.exited {
+		return os.ErrProcessDone
+	}
+	return signalProcess(x.cmd, sig)
+}
+
+// Kill stops the script immediately with SIGKILL. Wait then reports a
+// ScriptSignalError.
+func (x *Execution) Kill() error {
+	return x.Signal(os.Kill)
+}
+
+// stop runs the stop sequence: SIGINT, SIGTERM and SIGKILL, each sent only
+// if the script is still running after the previous one's grace period.
+// A signal with a zero grace period, or one that cannot be delivered on
+// this platform, is skipped.
+func (x *Execution) stop(interruptGrace, terminateGrace time.Duration) {
+	steps := []struct {
+		stage StopStage
+		sig   os.Signal
+		grace time.Duration
+	}{
+		{StopInterrupt, os.Interrupt, interruptGrace},
+		{StopTerminate, syscall.SIGTERM, terminateGrace},
+		{StopKill, os.Kill, 0},
+	}
+	for _, step := range steps {
+		if step.stage != StopKill && step.grace <= 0 {
+			continue
+		}
+		x.mu.Lock()
+		if x.exited {
+			x.mu.Unlock()
+			return
+		}
+		err := signalProcess(x.cmd, step.sig)
+		if err == nil {
+			x.stage = step.stage
+		}
+		x.mu.Unlock()
+		if err != nil || step.grace <= 0 {
+			continue
+		}
+		GetZlog().Info().Int("pid", x.cmd.Process.Pid).Str("stage", step.stage.String()).Dur("grace", step.grace).Msg("Stopping script")
+		select {
+		case <-x.done:
+			return
+		case <-time.After(step.grace):
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package pyexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecution(t *testing.T) {
+	t.Run("WaitAndPid", func(t *testing.T) {
+		script := writeScript(t, "pid.py", "import os\nprint(os.getpid())\n")
+		x, err := Start(script, nil)
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		res, err := x.Wait()
+		if err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+		if got := strings.TrimSpace(string(res.Stdout)); got != fmt.Sprint(x.Pid()) {
+			t.Errorf("Expected pid %d, script printed %q", x.Pid(), got)
+		}
+		select {
+		case <-x.Done():
+		default:
+			t.Error("Expected Done to be closed after Wait")
+		}
+		if again, _ := x.Wait(); again != res {
+			t.Error("Expected Wait to return the same Result every time")
+		}
+		if err := x.Signal(os.Interrupt); !errors.Is(err, os.ErrProcessDone) {
+			t.Errorf("Expected os.ErrProcessDone after exit, got %v", err)
+		}
+	})
+
+	t.Run("StdinPipe", func(t *testing.T) {
+		script := writeScript(t, "echo_lines.py", "import sys\nfor line in sys.stdin:\n    print(line.strip().upper(), flush=True)\n")
+		lines := make(chan string, 10)
+		x, err := NewExecutor().Start(context.Background(), script, nil, WithStdinPipe(),
+			WithLineHandler(func(stream Stream, line string) {
+				if stream == Stdout {
+					lines <- line
+				}
+			}))
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		for _, word := range []string{"one", "two"} {
+			fmt.Fprintln(x.Stdin(), word)
+			select {
+			case got := <-lines:
+				if got != strings.ToUpper(word) {
+					t.Errorf("Expected %q, got %q", strings.ToUpper(word), got)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Timed out waiting for the script's reply")
+			}
+		}
+		x.Stdin().Close()
+		res, err := x.Wait()
+		if err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+		if string(res.Stdout) != "ONE\nTWO\n" {
+			t.Errorf("Expected captured output %q, got %q", "ONE\nTWO\n", res.Stdout)
+		}
+	})
+
+	t.Run("KillOneOfMany", func(t *testing.T) {
+		script := writeScript(t, "sleeper.py", "import sys, time\ntime.sleep(float(sys.argv[1]))\n")
+		long, err := Start(script, []Arg{{Key: "30"}})
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		short, err := Start(script, []Arg{{Key: "0.1"}})
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if err := long.Kill(); err != nil {
+			t.Fatalf("Kill failed: %v", err)
+		}
+		select {
+		case <-long.Done():
+		case <-time.After(10 * time.Second):
+			t.Fatal("Killed script did not exit")
+		}
+		if res, err := long.Wait(); err == nil || res.Success() {
+			t.Errorf("Expected the killed script to fail, got %v", err)
+		}
+		if _, err := short.Wait(); err != nil {
+			t.Errorf("Expected the other script to finish normally, got %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		script := writeScript(t, "slow.py", "import time\ntime.sleep(30)\n")
+		x, err := Start(script, nil, WithTimeout(200*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if _, err := x.Wait(); !errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected ErrTimeout, got %v", err)
+		}
+	})
+
+	t.Run("ScriptNotFound", func(t *testing.T) {
+		if _, err := Start("no_such_script.py", nil); !errors.Is(err, ErrScriptNotFound) {
+			t.Errorf("Expected ErrScriptNotFound, got %v", err)
+		}
+	})
+}
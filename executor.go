@@ -0,0 +1,297 @@
+package pyexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Executor runs Python scripts with a fixed configuration.
+// Configure it once with NewExecutor and share it; it is safe for
+// concurrent use.
+type Executor struct {
+	cfg config
+}
+
+// NewExecutor returns an Executor configured by opts.
+// For BackendPython the interpreter is resolved here, once, unless
+// WithInterpreter is given.
+func NewExecutor(opts ...Option) *Executor {
+	e := &Executor{cfg: config{
+		injectedEnv:    defaultInjectedEnv,
+		interruptGrace: defaultGracePeriod,
+		terminateGrace: defaultGracePeriod,
+		stdout:         os.Stdout,
+		stderr:         os.Stderr,
+		stdoutPrefix:   "[stdout] ",
+		stderrPrefix:   "[stderr] ",
+	}}
+	for _, opt := range opts {
+		opt(&e.cfg)
+	}
+	if e.cfg.backend == BackendPython && e.cfg.interpreter == "" {
+		e.cfg.interpreter = getPythonCommand()
+	}
+	return e
+}
+
+// configFor returns the executor's configuration for scriptName, with the
+// options registered for the script and then opts applied on top.
+func (e *Executor) configFor(scriptName string, opts []Option) config {
+	c := e.cfg.clone()
+	c.forScript(scriptName)
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// command builds the command that runs scriptName with args.
+func (c *config) command(ctx context.Context, scriptName string, args []Arg) (*exec.Cmd, error) {
+	scriptPath, err := findScript(scriptName, c.scriptDirs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find python script: %w", err)
+	}
+	// Run Python in unbuffered mode (-u) so output arrives as it is written
+	pyArgs := append([]string{"-u", scriptPath}, argv(args)...)
+	cmd, err := c.pythonCommand(ctx, filepath.Dir(scriptPath), pyArgs)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c.stdin != nil:
+		cmd.Stdin = c.stdin
+	case c.stdinData != nil:
+		cmd.Stdin = bytes.NewReader(c.stdinData)
+	}
+	return cmd, nil
+}
+
+// pythonCommand builds a command that runs the backend's Python interpreter
+// with pyArgs, in the configured environment and working directory, which
+// defaults to scriptDir, the directory of the code it runs, if any.
+func (c *config) pythonCommand(ctx context.Context, scriptDir string, pyArgs []string) (*exec.Cmd, error) {
+	var program string
+	var cmdArgs []string
+	switch c.backend {
+	case BackendPython:
+		program = c.interpreter
+		if program == "" {
+			program = getPythonCommand()
+		}
+	case BackendUV:
+		if err := EnsureUVInstalled(); err != nil {
+			return nil, fmt.Errorf("%w: failed to ensure uv is installed: %w", ErrInterpreterNotFound, err)
+		}
+		program = "uv"
+		cmdArgs = append(cmdArgs, "run")
+		if c.isolateNetwork {
+			// Nothing can be downloaded inside the network namespace, so
+			// fail fast on missing packages instead of timing out.
+			cmdArgs = append(cmdArgs, "--offline")
+		}
+		if c.interpreter != "" {
+			cmdArgs = append(cmdArgs, "--python", c.interpreter)
+		}
+		cmdArgs = append(cmdArgs, "--", "python")
+	default:
+		return nil, fmt.Errorf("unsupported backend: %v", c.backend)
+	}
+	cmdArgs = append(cmdArgs, pyArgs...)
+
+	programPath, err := exec.LookPath(program)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInterpreterNotFound, program, err)
+	}
+	settings, err := c.launcherSettings(scriptDir)
+	if err != nil {
+		return nil, err
+	}
+	if settings != nil {
+		// The launcher needs a Python of its own; for uv, the interpreter
+		// setting is a version request rather than an executable.
+		python := programPath
+		if c.backend == BackendUV {
+			python = getPythonCommand()
+		}
+		if program, cmdArgs, err = withLauncher(python, programPath, cmdArgs, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, program, cmdArgs...)
+	cmd.Dir = c.dir
+	if cmd.Dir == "" {
+		cmd.Dir = scriptDir
+	}
+	cmd.Env = c.environ()
+	setProcessGroup(cmd)
+	if c.credential != nil {
+		if err := setCredential(cmd, c.credential); err != nil {
+			return nil, err
+		}
+	}
+	if c.isolateNetwork {
+		if err := isolateNetwork(cmd); err != nil {
+			return nil, err
+		}
+	}
+	return cmd, nil
+}
+
+// argv flattens args into command-line arguments, omitting empty values
+// so flags stand alone.
+func argv(args []Arg) []string {
+	out := make([]string, 0, 2*len(args))
+	for _, arg := range args {
+		out = append(out, arg.Key)
+		if arg.Value != "" {
+			out = append(out, arg.Value)
+		}
+	}
+	return out
+}
+
+// environ builds the script's environment: the inherited variables allowed
+// by the env mode, then the injected ones and those pointing into the
+// execution's directory, if it has one, then those from WithEnv.
+// exec.Cmd keeps the last value of duplicated keys, so later layers win.
+func (c *config) environ() []string {
+	var env []string
+	switch c.envMode {
+	case EnvInherit:
+		env = os.Environ()
+	case EnvAllowlist:
+		for _, kv := range os.Environ() {
+			name, _, _ := strings.Cut(kv, "=")
+			if envAllowed(name, c.envAllow) {
+				env = append(env, kv)
+			}
+		}
+	}
+	env = append(env, c.injectedEnv...)
+	if c.execDir != nil {
+		env = append(env, "TMPDIR="+c.execDir.tmp())
+		if c.workspace != nil {
+			env = append(env, "HOME="+c.execDir.path, "PYEXEC_OUTPUT_DIR="+c.execDir.outputs())
+		}
+	}
+	return append(env, c.env...)
+}
+
+// envAllowed reports whether name matches one of the allowlist patterns.
+func envAllowed(name string, allow []string) bool {
+	for _, pattern := range allow {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// withTimeout applies the configured timeout, if any, to ctx.
+func (c *config) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(ctx, c.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// terminationReason tells why res, the result of an execution under ctx,
+// failed, if it was not just the script's own exit status.
+func (c *config) terminationReason(ctx context.Context, res *Result) TerminationReason {
+	switch ctxErr := ctx.Err(); {
+	case res.Success():
+		return ReasonNone
+	case errors.Is(context.Cause(ctx), ErrIdleTimeout):
+		return ReasonIdleTimeout
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		return ReasonTimeout
+	case ctxErr != nil:
+		return ReasonCanceled
+	}
+	if c.isolateNetwork && networkBlocked(res) {
+		return ReasonNetworkBlocked
+	}
+	return c.limits.reason(res)
+}
+
+// Run executes the script and returns its Result once it exits.
+// If the script fails, the error is accompanied by the Result whenever the
+// script was started, so stderr and the exit status are still available.
+// Options override the executor's configuration for this call only.
+func (e *Executor) Run(ctx context.Context, scriptName string, args []Arg, opts ...Option) (*Result, error) {
+	c := e.configFor(scriptName, opts)
+	return c.run(ctx, scriptName, args, nil)
+}
+
+// Stream executes the script, passing its stdout and stderr line by line
+// to the configured writers or LineHandler while it runs, and returns its
+// Result once it exits. Like Run, a failed script still yields its Result.
+// Options override the executor's configuration for this call only.
+func (e *Executor) Stream(ctx context.Context, scriptName string, args []Arg, opts ...Option) (*Result, error) {
+	c := e.configFor(scriptName, opts)
+	return c.run(ctx, scriptName, args, newLineSink(&c))
+}
+
+// run starts the script and waits for it. There is nobody to write to a
+// stdin pipe, so it is closed right away.
+func (c *config) run(ctx context.Context, scriptName string, args []Arg, sink *lineSink) (*Result, error) {
+	x, err := c.start(ctx, scriptName, args, sink)
+	if err != nil {
+		return nil, err
+	}
+	if x.stdin != nil {
+		x.stdin.Close()
+	}
+	return x.Wait()
+}
+
+// executionError converts the error from waiting on a script into one of
+// the package's error types. A done context takes precedence, since the
+// script was killed because of it. err is nil for executions that report
+// a failed exit status in res only, such as pool and fork server runs.
+func executionError(ctx context.Context, scriptName, dir string, res *Result, err error) error {
+	switch ctxErr := ctx.Err(); {
+	case errors.Is(context.Cause(ctx), ErrIdleTimeout):
+		return fmt.Errorf("python script '%s' (in dir %s) was stopped: %w", scriptName, dir, context.Cause(ctx))
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		return fmt.Errorf("python script '%s' (in dir %s) was stopped: %w: %w", scriptName, dir, ErrTimeout, ctxErr)
+	case ctxErr != nil:
+		return fmt.Errorf("python script '%s' (in dir %s) was stopped: %w", scriptName, dir, ctxErr)
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("python script '%s' (in dir %s) execution failed: %w", scriptName, dir, err)
+	}
+	if res.Signal != nil {
+		return &ScriptSignalError{
+			Script: scriptName,
+			Dir:    dir,
+			Signal: res.Signal,
+			Reason: res.Reason,
+			Stdout: res.Stdout,
+			Stderr: res.Stderr,
+			Err:    err,
+		}
+	}
+	return &ScriptExitError{
+		Script:   scriptName,
+		Dir:      dir,
+		ExitCode: res.ExitCode,
+		Reason:   res.Reason,
+		Stdout:   res.Stdout,
+		Stderr:   res.Stderr,
+		Err:      err,
+	}
+}
@@ -0,0 +1,214 @@
+package pyexec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecutor(t *testing.T) {
+	t.Run("ScriptDirs", func(t *testing.T) {
+		script := writeScript(t, "only_in_dir.py", "print('found')\n")
+		e := NewExecutor(WithScriptDirs(filepath.Dir(script)))
+		res, err := e.Run(context.Background(), "only_in_dir.py", nil)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if got := strings.TrimSpace(string(res.Stdout)); got != "found" {
+			t.Errorf("Expected %q, got %q", "found", got)
+		}
+	})
+
+	t.Run("EnvAndWorkDir", func(t *testing.T) {
+		script := writeScript(t, "env.py", "import os\nprint(os.environ['MODEL_NAME'])\nprint(os.getcwd())\n")
+		workDir, err := filepath.EvalSymlinks(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := NewExecutor(WithEnv("MODEL_NAME=base"))
+		res, err := e.Run(context.Background(), script, nil, WithEnv("MODEL_NAME=override"), WithWorkDir(workDir))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(res.Stdout)), "\n")
+		if len(lines) != 2 || lines[0] != "override" || lines[1] != workDir {
+			t.Errorf("Expected [override %s], got %q", workDir, lines)
+		}
+	})
+
+	t.Run("EnvModes", func(t *testing.T) {
+		script := writeScript(t, "dump_env.py", "import json, os\nprint(json.dumps(dict(os.environ)))\n")
+		t.Setenv("PYEXEC_TEST_SECRET", "hunter2")
+		t.Setenv("PYEXEC_TEST_ALLOWED", "yes")
+
+		run := func(opts ...Option) map[string]string {
+			t.Helper()
+			res, err := NewExecutor(opts...).Run(context.Background(), script, nil)
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			var env map[string]string
+			if err := json.Unmarshal(res.Stdout, &env); err != nil {
+				t.Fatalf("Failed to parse environment: %v\nOutput: %s", err, res.Stdout)
+			}
+			return env
+		}
+
+		env := run()
+		if env["PYEXEC_TEST_SECRET"] != "hunter2" || env["PYTHONIOENCODING"] != "utf-8" || env["PYTHONUNBUFFERED"] != "1" {
+			t.Errorf("EnvInherit: expected inherited and injected variables, got %v", env)
+		}
+
+		env = run(WithEnvAllowlist("PYEXEC_TEST_ALLOW*"), WithEnv("MODEL_NAME=small"))
+		if _, leaked := env["PYEXEC_TEST_SECRET"]; leaked || env["PYEXEC_TEST_ALLOWED"] != "yes" || env["MODEL_NAME"] != "small" {
+			t.Errorf("EnvAllowlist: unexpected environment %v", env)
+		}
+
+		env = run(WithEnvMode(EnvClean), WithInjectedEnv("PYTHONIOENCODING=latin-1"))
+		if _, leaked := env["PYEXEC_TEST_ALLOWED"]; leaked || env["PYTHONIOENCODING"] != "latin-1" {
+			t.Errorf("EnvClean: unexpected environment %v", env)
+		}
+		if _, ok := env["PYTHONUNBUFFERED"]; ok {
+			t.Errorf("EnvClean: expected PYTHONUNBUFFERED to be dropped by WithInjectedEnv, got %v", env)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		script := writeScript(t, "sleepy.py", "import time\ntime.sleep(30)\n")
+		e := NewExecutor(WithTimeout(300 * time.Millisecond))
+		_, err := e.Run(context.Background(), script, nil)
+		if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected error to match ErrTimeout and context.DeadlineExceeded, but got: %v", err)
+		}
+	})
+
+	t.Run("IdleTimeout", func(t *testing.T) {
+		script := writeScript(t, "stalls.py", `import sys, time
+for i in range(5):
+    print("progress", i, flush=True)
+    time.sleep(0.1)
+time.sleep(30)
+`)
+		e := NewExecutor(WithIdleTimeout(400*time.Millisecond), WithGracePeriods(0, 0))
+		res, err := e.Run(context.Background(), script, nil)
+		if !errors.Is(err, ErrIdleTimeout) || errors.Is(err, ErrTimeout) || errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected error to match only ErrIdleTimeout, but got: %v", err)
+		}
+		if res.Reason != ReasonIdleTimeout || !strings.Contains(string(res.Stdout), "progress 4") {
+			t.Errorf("Expected an idle timeout after the progress output, got reason %q, stdout %q", res.Reason, res.Stdout)
+		}
+		if res.Duration > 5*time.Second {
+			t.Errorf("Expected the script to be stopped promptly, took %v", res.Duration)
+		}
+	})
+
+	t.Run("StreamSinks", func(t *testing.T) {
+		script := writeScript(t, "both.py", "import sys\nprint('out')\nprint('err', file=sys.stderr)\n")
+		var stdout, stderr bytes.Buffer
+		e := NewExecutor(WithStdout(&stdout), WithStderr(&stderr))
+		res, err := e.Stream(context.Background(), script, nil)
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if string(res.Stdout) != "out\n" || string(res.Stderr) != "err\n" {
+			t.Errorf("Unexpected captured output: stdout %q, stderr %q", res.Stdout, res.Stderr)
+		}
+		if stdout.String() != "[stdout] out\n" {
+			t.Errorf("Unexpected stdout sink content: %q", stdout.String())
+		}
+		if stderr.String() != "[stderr] err\n" {
+			t.Errorf("Unexpected stderr sink content: %q", stderr.String())
+		}
+	})
+
+	t.Run("LineHandler", func(t *testing.T) {
+		script := writeScript(t, "lines.py", "import sys\nprint('a')\nprint('b', file=sys.stderr)\nprint('c')\n")
+		got := map[Stream][]string{}
+		_, err := NewExecutor().Stream(context.Background(), script, nil, WithLineHandler(func(stream Stream, line string) {
+			got[stream] = append(got[stream], line)
+		}))
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if !reflect.DeepEqual(got[Stdout], []string{"a", "c"}) || !reflect.DeepEqual(got[Stderr], []string{"b"}) {
+			t.Errorf("Unexpected lines: %v", got)
+		}
+	})
+
+	t.Run("PrefixesAndTimestamps", func(t *testing.T) {
+		script := writeScript(t, "one.py", "print('hi')\n")
+		var out bytes.Buffer
+		_, err := NewExecutor(WithStdout(&out), WithPrefixes("", "")).Stream(context.Background(), script, nil, WithTimestamps("2006"))
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if want := time.Now().Format("2006") + " hi\n"; out.String() != want {
+			t.Errorf("Expected %q, got %q", want, out.String())
+		}
+	})
+
+	t.Run("Stdin", func(t *testing.T) {
+		script := writeScript(t, "upper.py", "import sys\nsys.stdout.write(sys.stdin.read().upper())\n")
+		payload := strings.Repeat("row,1,2\n", 50000) // larger than a pipe buffer
+		e := NewExecutor(WithStdout(io.Discard), WithStderr(io.Discard))
+
+		res, err := e.Run(context.Background(), script, nil, WithStdin(strings.NewReader(payload)))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if string(res.Stdout) != strings.ToUpper(payload) {
+			t.Errorf("Run: stdout does not match upper-cased stdin (%d vs %d bytes)", len(res.Stdout), len(payload))
+		}
+
+		res, err = e.Stream(context.Background(), script, nil, WithStdinBytes([]byte("abc")))
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if string(res.Stdout) != "ABC" {
+			t.Errorf("Stream: expected %q, got %q", "ABC", res.Stdout)
+		}
+	})
+
+	t.Run("Result", func(t *testing.T) {
+		script := writeScript(t, "fail.py", "import sys\nprint('partial')\nprint('boom', file=sys.stderr)\nsys.exit(3)\n")
+		res, err := NewExecutor().Run(context.Background(), script, nil)
+		if err == nil {
+			t.Fatal("Expected an error for a non-zero exit, but got nil")
+		}
+		if res == nil {
+			t.Fatal("Expected a Result alongside the error, but got nil")
+		}
+		var exitErr *ScriptExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode != 3 || string(exitErr.Stderr) != "boom\n" {
+			t.Errorf("Expected a ScriptExitError with code 3 and stderr attached, but got: %v", err)
+		}
+		if res.ExitCode != 3 || res.Signal != nil || res.Success() {
+			t.Errorf("Expected exit code 3 and no signal, got %d / %v", res.ExitCode, res.Signal)
+		}
+		if string(res.Stdout) != "partial\n" || string(res.Stderr) != "boom\n" {
+			t.Errorf("Unexpected captured output: stdout %q, stderr %q", res.Stdout, res.Stderr)
+		}
+		if res.Duration <= 0 || res.UserTime+res.SystemTime <= 0 {
+			t.Errorf("Expected positive duration and CPU time, got %v / %v+%v", res.Duration, res.UserTime, res.SystemTime)
+		}
+		if runtime.GOOS == "linux" && res.MaxRSS <= 0 {
+			t.Errorf("Expected MaxRSS to be reported, got %d", res.MaxRSS)
+		}
+	})
+
+	t.Run("InterpreterNotFound", func(t *testing.T) {
+		e := NewExecutor(WithInterpreter(filepath.Join(os.TempDir(), "no-such-python")))
+		if _, err := e.Run(context.Background(), "test_script.py", nil); !errors.Is(err, ErrInterpreterNotFound) {
+			t.Errorf("Expected error to match ErrInterpreterNotFound, but got: %v", err)
+		}
+	})
+}
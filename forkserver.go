@@ -0,0 +1,235 @@
+package pyexec
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+//go:embed forkserver.py
+var forkServerSource []byte
+
+// forkServerHelper holds forkserver.py, which runs from a file, like the
+// launcher.
+var forkServerHelper = &helperDir{file: "pyexec_forkserver.py", source: forkServerSource}
+
+// ErrForkServerClosed is returned by ForkServer.Run after the server has
+// been closed or its parent process has exited.
+var ErrForkServerClosed = errors.New("fork server closed")
+
+// ForkServer runs scripts in children forked from a long-lived Python
+// process that has already imported a list of modules. Every run gets a
+// fresh process, so global state never leaks between runs, without paying
+// for the interpreter start-up and the imports again.
+//
+// The interpreter, backend and inherited environment are those of the
+// server process, fixed by the Executor that created it. Per run, scripts
+// are located as usual and WithEnv, WithWorkDir, WithTimeout and the output
+// capture options apply; the script's stdin is empty.
+type ForkServer struct {
+	cfg config
+	s   *shim
+
+	// writeMu serializes request frames; mu guards pending, nextID and
+	// closed.
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[uint64]*forkRun
+	nextID  uint64
+	closed  bool
+	// done is closed when the server stops responding.
+	done chan struct{}
+}
+
+// forkRequest and forkResponse mirror the frames of forkserver.py.
+type forkRequest struct {
+	Op     string            `json:"op"`
+	ID     uint64            `json:"id"`
+	Script string            `json:"script,omitempty"`
+	Argv   []string          `json:"argv,omitempty"`
+	Cwd    string            `json:"cwd,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+	Signal int               `json:"signal,omitempty"`
+}
+
+// The output of a run is sent in outputFrames ahead of its final
+// forkResponse.
+type forkResponse struct {
+	outputFrame
+	ID       uint64 `json:"id"`
+	ExitCode int    `json:"exit_code"`
+	Signal   int    `json:"signal"`
+}
+
+// forkRun is a run waiting for its final response. Its output is captured
+// by readResponses as it arrives.
+type forkRun struct {
+	stdout, stderr *capture
+	done           chan forkResponse
+}
+
+// NewForkServer starts a fork server that pre-imports modules, configured
+// like the executor's scripts. An import error is reported here. The fork
+// server requires a platform with fork(2).
+func (e *Executor) NewForkServer(ctx context.Context, modules ...string) (*ForkServer, error) {
+	c := e.configFor("", nil)
+	s, err := startShim(ctx, &c, "fork server", forkServerHelper, "", modules...)
+	if err != nil {
+		return nil, err
+	}
+	fs := &ForkServer{
+		cfg:     c,
+		s:       s,
+		pending: make(map[uint64]*forkRun),
+		done:    make(chan struct{}),
+	}
+	go fs.readResponses()
+	return fs, nil
+}
+
+// readResponses dispatches response frames to the runs waiting for them.
+func (fs *ForkServer) readResponses() {
+	defer close(fs.done)
+	for {
+		var resp forkResponse
+		if err := readFrame(fs.s.responses, &resp); err != nil {
+			fs.mu.Lock()
+			fs.closed = true
+			fs.mu.Unlock()
+			return
+		}
+		fs.mu.Lock()
+		run := fs.pending[resp.ID]
+		if resp.Output == "" {
+			delete(fs.pending, resp.ID)
+		}
+		fs.mu.Unlock()
+		switch {
+		case run == nil:
+		case resp.Output != "":
+			resp.capture(run.stdout, run.stderr)
+		default:
+			run.done <- resp
+		}
+	}
+}
+
+// send writes one request frame.
+func (fs *ForkServer) send(req forkRequest) error {
+	fs.writeMu.Lock()
+	defer fs.writeMu.Unlock()
+	return writeFrame(fs.s.requests, req)
+}
+
+// Run executes scriptName in a freshly forked child and returns its Result
+// and errors exactly like Executor.Run. CPU time and MaxRSS are not
+// reported for forked runs.
+func (fs *ForkServer) Run(ctx context.Context, scriptName string, args []Arg, opts ...Option) (*Result, error) {
+	c := fs.cfg.clone()
+	c.forScript(scriptName)
+	for _, opt := range opts {
+		opt(&c)
+	}
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	scriptPath, err := findScript(scriptName, c.scriptDirs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find python script: %w", err)
+	}
+	req := forkRequest{
+		Op:     "run",
+		Script: scriptPath,
+		Argv:   argv(args),
+		Cwd:    c.dir,
+		Env:    make(map[string]string, len(c.env)),
+	}
+	if req.Cwd == "" {
+		req.Cwd = filepath.Dir(scriptPath)
+	}
+	for _, kv := range c.env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			req.Env[k] = v
+		}
+	}
+
+	run := &forkRun{
+		stdout: newCapture(&c, Stdout),
+		stderr: newCapture(&c, Stderr),
+		done:   make(chan forkResponse, 1),
+	}
+	fs.mu.Lock()
+	if fs.closed {
+		fs.mu.Unlock()
+		return nil, ErrForkServerClosed
+	}
+	fs.nextID++
+	req.ID = fs.nextID
+	fs.pending[req.ID] = run
+	fs.mu.Unlock()
+
+	GetZlog().Info().Str("script", scriptPath).Strs("args", req.Argv).Msg("Executing script in fork server")
+	start := time.Now()
+	if err := fs.send(req); err != nil {
+		fs.mu.Lock()
+		delete(fs.pending, req.ID)
+		fs.mu.Unlock()
+		return nil, fmt.Errorf("%w: %v", ErrForkServerClosed, err)
+	}
+
+	var resp forkResponse
+	select {
+	case resp = <-run.done:
+	case <-fs.done:
+		run.discard()
+		return nil, ErrForkServerClosed
+	case <-ctx.Done():
+		// Kill the child's process group and wait for its exit to be
+		// reported, so no run outlives the call.
+		fs.send(forkRequest{Op: "kill", ID: req.ID, Signal: int(syscall.SIGKILL)})
+		select {
+		case resp = <-run.done:
+		case <-fs.done:
+			run.discard()
+			return nil, ErrForkServerClosed
+		}
+	}
+
+	res := capturedResult(start, run.stdout, run.stderr)
+	res.ExitCode = resp.ExitCode
+	if resp.Signal != 0 {
+		res.Signal = syscall.Signal(resp.Signal)
+	}
+	res.Reason = c.terminationReason(ctx, res)
+	if !res.Success() || ctx.Err() != nil {
+		return res, executionError(ctx, scriptName, req.Cwd, res, nil)
+	}
+	return res, nil
+}
+
+// discard drops the output of a run that will get no response; it must
+// only be called once readResponses has returned.
+func (r *forkRun) discard() {
+	for _, c := range []*capture{r.stdout, r.stderr} {
+		if _, path := c.finish(); path != "" {
+			os.Remove(path)
+		}
+	}
+}
+
+// Close stops the server. Runs still in progress are killed.
+func (fs *ForkServer) Close() error {
+	fs.mu.Lock()
+	fs.closed = true
+	fs.mu.Unlock()
+	fs.s.stop()
+	<-fs.done
+	return nil
+}
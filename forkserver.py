@@ -0,0 +1,215 @@
+"""Fork server for pyexec.ForkServer.
+
+Imports the given modules once, then forks a fresh child for every run
+request read from stdin. Each child runs its target script as __main__ in
+a new process group, with its own stdout and stderr pipes; the parent
+forwards the output in chunks as it arrives, so it never holds a run's
+whole output, and then reports the exit status. Requests and responses are
+frames of a 4-byte big-endian length followed by a JSON document, as in
+pool_worker.py.
+
+The parent is single-threaded, so forking is safe while runs are in
+flight.
+
+Usage: python -u pyexec_forkserver.py <module> ...
+"""
+import base64
+import importlib
+import json
+import os
+import runpy
+import selectors
+import signal
+import struct
+import sys
+import traceback
+
+READ_SIZE = 65536
+
+
+class Run:
+    def __init__(self, run_id, pid, out_fd, err_fd):
+        self.id = run_id
+        self.pid = pid
+        self.streams = {out_fd: "stdout", err_fd: "stderr"}
+        self.open = 2
+
+
+class Server:
+    def __init__(self, responses):
+        self.responses = responses
+        self.selector = selectors.DefaultSelector()
+        self.requests = bytearray()
+        self.runs = {}
+        self.by_fd = {}
+        self.reaping = []
+        self.closing = False
+
+    def send(self, message):
+        data = json.dumps(message).encode("utf-8")
+        self.responses.write(struct.pack(">I", len(data)) + data)
+        self.responses.flush()
+
+    def serve(self):
+        self.selector.register(0, selectors.EVENT_READ)
+        while not self.closing or self.runs:
+            timeout = 0.05 if self.reaping else None
+            for key, _ in self.selector.select(timeout):
+                if key.fd == 0:
+                    self.read_requests()
+                else:
+                    self.read_output(key.fd)
+            self.reap()
+
+    def read_requests(self):
+        data = os.read(0, READ_SIZE)
+        if not data:
+            # Go closed the pipe: stop everything still running and exit.
+            self.selector.unregister(0)
+            self.closing = True
+            for run in self.runs.values():
+                kill(run.pid, signal.SIGKILL)
+            return
+        self.requests += data
+        while len(self.requests) >= 4:
+            (size,) = struct.unpack(">I", self.requests[:4])
+            if len(self.requests) < 4 + size:
+                break
+            request = json.loads(self.requests[4 : 4 + size])
+            del self.requests[: 4 + size]
+            if request["op"] == "run":
+                self.start(request)
+            elif request["op"] == "kill":
+                run = self.runs.get(request["id"])
+                if run is not None:
+                    kill(run.pid, request.get("signal", signal.SIGKILL))
+
+    def start(self, request):
+        out_r, out_w = os.pipe()
+        err_r, err_w = os.pipe()
+        pid = os.fork()
+        if pid == 0:
+            os.close(out_r)
+            os.close(err_r)
+            self.become_child(request, out_w, err_w)
+        # Set the child's process group from both sides, so a kill request
+        # that arrives before the child runs still reaches it.
+        try:
+            os.setpgid(pid, pid)
+        except OSError:
+            pass
+        os.close(out_w)
+        os.close(err_w)
+        run = Run(request["id"], pid, out_r, err_r)
+        self.runs[run.id] = run
+        for fd in (out_r, err_r):
+            self.by_fd[fd] = run
+            self.selector.register(fd, selectors.EVENT_READ)
+
+    def become_child(self, request, out_w, err_w):
+        code = 1
+        try:
+            os.setpgid(0, 0)
+            self.selector.close()
+            self.responses.close()
+            for fd in self.by_fd:
+                os.close(fd)
+            devnull = os.open(os.devnull, os.O_RDONLY)
+            os.dup2(devnull, 0)
+            os.dup2(out_w, 1)
+            os.dup2(err_w, 2)
+            for fd in (devnull, out_w, err_w):
+                os.close(fd)
+
+            script = request["script"]
+            os.chdir(request["cwd"])
+            os.environ.update(request.get("env") or {})
+            sys.argv = [script] + (request.get("argv") or [])
+            sys.path[0] = os.path.dirname(script)
+            runpy.run_path(script, run_name="__main__")
+            code = 0
+        except SystemExit as e:
+            if e.code is None:
+                code = 0
+            elif isinstance(e.code, int):
+                code = e.code
+            else:
+                print(e.code, file=sys.stderr)
+        except BaseException:
+            traceback.print_exc()
+        finally:
+            try:
+                sys.stdout.flush()
+                sys.stderr.flush()
+            finally:
+                os._exit(code)
+
+    def read_output(self, fd):
+        run = self.by_fd[fd]
+        data = os.read(fd, READ_SIZE)
+        if data:
+            if not self.closing:
+                self.send({
+                    "id": run.id,
+                    "output": run.streams[fd],
+                    "data": base64.b64encode(data).decode("ascii"),
+                })
+            return
+        self.selector.unregister(fd)
+        os.close(fd)
+        del self.by_fd[fd]
+        run.open -= 1
+        if run.open == 0:
+            self.reaping.append(run)
+
+    def reap(self):
+        for run in list(self.reaping):
+            pid, status = os.waitpid(run.pid, os.WNOHANG)
+            if pid == 0:
+                continue
+            self.reaping.remove(run)
+            del self.runs[run.id]
+            if self.closing:
+                continue
+            exit_code, sig = -1, 0
+            if os.WIFSIGNALED(status):
+                sig = os.WTERMSIG(status)
+            else:
+                exit_code = os.WEXITSTATUS(status)
+            self.send({"id": run.id, "exit_code": exit_code, "signal": sig})
+
+
+def kill(pid, sig):
+    try:
+        os.killpg(pid, sig)
+    except ProcessLookupError:
+        pass
+
+
+def unshadow_path():
+    # Run from a file, Python puts this file's directory first on sys.path;
+    # resolve imports from the working directory instead, as with -c.
+    if sys.path and sys.path[0] == os.path.dirname(os.path.abspath(__file__)):
+        sys.path[0] = ""
+
+
+def main():
+    unshadow_path()
+    responses = os.fdopen(os.dup(1), "wb")
+    # From here on anything written to fd 1 goes to stderr instead of
+    # corrupting the protocol.
+    os.dup2(2, 1)
+    server = Server(responses)
+    try:
+        for name in sys.argv[1:]:
+            importlib.import_module(name)
+    except BaseException:
+        server.send({"ready": False, "error": traceback.format_exc()})
+        return 1
+    server.send({"ready": True})
+    server.serve()
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,146 @@
+package pyexec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForkServer(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewExecutor().NewForkServer(ctx, "json", "decimal")
+	if err != nil {
+		t.Fatalf("NewForkServer failed: %v", err)
+	}
+	defer fs.Close()
+
+	t.Run("MatchesExecutePythonScript", func(t *testing.T) {
+		args := []Arg{{Key: "--arg1", Value: "value1"}, {Key: "--flag"}}
+		want, err := ExecutePythonScript("test_script.py", args)
+		if err != nil {
+			t.Fatalf("ExecutePythonScript failed: %v", err)
+		}
+		res, err := fs.Run(ctx, "test_script.py", args)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if string(res.Stdout) != string(want) {
+			t.Errorf("Expected stdout %q, got %q", want, res.Stdout)
+		}
+	})
+
+	t.Run("FreshStatePerRun", func(t *testing.T) {
+		script := writeScript(t, "stateful.py", `import decimal, os, sys
+print(getattr(decimal, "pyexec_marker", "clean"), os.getpid(), os.getcwd(), os.environ.get("MODE"))
+decimal.pyexec_marker = "dirty"
+`)
+		first, err := fs.Run(ctx, script, nil, WithEnv("MODE=a"))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		second, err := fs.Run(ctx, script, nil)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		a, b := strings.Fields(string(first.Stdout)), strings.Fields(string(second.Stdout))
+		if a[0] != "clean" || b[0] != "clean" || a[1] == b[1] {
+			t.Errorf("Expected clean module state in separate processes, got %q and %q", first.Stdout, second.Stdout)
+		}
+		if a[3] != "a" || b[3] != "None" {
+			t.Errorf("Expected WithEnv to apply to the first run only, got %q and %q", a[3], b[3])
+		}
+		if wd, _ := os.Getwd(); a[2] == wd {
+			t.Errorf("Expected the run to happen in the script's directory, got %s", a[2])
+		}
+	})
+
+	t.Run("ExitCodeAndStderr", func(t *testing.T) {
+		script := writeScript(t, "fails.py", "import sys\nprint('out')\nsys.stderr.buffer.write(b'\\xff raw\\n')\nsys.exit(5)\n")
+		res, err := fs.Run(ctx, script, nil)
+		var exitErr *ScriptExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode != 5 {
+			t.Fatalf("Expected a ScriptExitError with code 5, got %v", err)
+		}
+		if string(res.Stdout) != "out\n" || string(res.Stderr) != "\xff raw\n" {
+			t.Errorf("Unexpected output: stdout %q, stderr %q", res.Stdout, res.Stderr)
+		}
+	})
+
+	t.Run("OutputLimitAndSpill", func(t *testing.T) {
+		script := writeScript(t, "forked_flood.py", "import sys\nsys.stdout.write('x' * 1000000)\nsys.stderr.write('y' * 100)\n")
+		res, err := fs.Run(ctx, script, nil, WithOutputLimit(0, 10), WithSpill(1000, t.TempDir()))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		defer res.Close()
+		if res.Stdout != nil {
+			t.Errorf("Expected stdout to be spilled, got %d bytes in memory", len(res.Stdout))
+		}
+		if string(res.Stderr) != "yyyyyyyyyy" || !res.StderrTruncated {
+			t.Errorf("Expected 10 bytes of truncated stderr, got %q", res.Stderr)
+		}
+		r, err := res.OpenStdout()
+		if err != nil {
+			t.Fatalf("OpenStdout failed: %v", err)
+		}
+		defer r.Close()
+		stdout, err := io.ReadAll(r)
+		if err != nil || len(stdout) != 1000000 {
+			t.Errorf("Expected 1000000 bytes of spilled stdout, got %d (%v)", len(stdout), err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		script := writeScript(t, "forked_sleep.py", "import time\ntime.sleep(30)\n")
+		start := time.Now()
+		_, err := fs.Run(ctx, script, nil, WithTimeout(300*time.Millisecond))
+		if !errors.Is(err, ErrTimeout) {
+			t.Fatalf("Expected ErrTimeout, got %v", err)
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Errorf("Run was not stopped promptly")
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		script := writeScript(t, "nap.py", "import time\ntime.sleep(0.5)\nprint('done')\n")
+		start := time.Now()
+		errs := make(chan error, 4)
+		for i := 0; i < 4; i++ {
+			go func() {
+				_, err := fs.Run(ctx, script, nil)
+				errs <- err
+			}()
+		}
+		for i := 0; i < 4; i++ {
+			if err := <-errs; err != nil {
+				t.Errorf("Run failed: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed > 1900*time.Millisecond {
+			t.Errorf("Expected runs to overlap, took %v", elapsed)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		other, err := NewExecutor().NewForkServer(ctx)
+		if err != nil {
+			t.Fatalf("NewForkServer failed: %v", err)
+		}
+		other.Close()
+		if _, err := other.Run(ctx, "test_script.py", nil); !errors.Is(err, ErrForkServerClosed) {
+			t.Errorf("Expected ErrForkServerClosed, got %v", err)
+		}
+	})
+
+	t.Run("ImportError", func(t *testing.T) {
+		_, err := NewExecutor(WithStderr(nil)).NewForkServer(ctx, "no_such_module_pyexec")
+		if err == nil || !strings.Contains(err.Error(), "ModuleNotFoundError") {
+			t.Errorf("Expected a ModuleNotFoundError, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package pyexec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxFrameSize bounds a single protocol frame exchanged with the embedded
+// Python shims, so a corrupted length prefix cannot exhaust memory.
+const maxFrameSize = 1 << 30
+
+// writeFrame writes v as a JSON document preceded by its 4-byte big-endian
+// length.
+func writeFrame(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(data)), uint32(len(data)))
+	_, err = w.Write(append(frame, data...))
+	return err
+}
+
+// readFrame reads one frame written by writeFrame (or the Python side's
+// send) and decodes it into v.
+func readFrame(r io.Reader, v any) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return fmt.Errorf("protocol frame of %d bytes exceeds the %d byte limit", size, maxFrameSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// outputFrame is a chunk of a script's output. The shims send these while
+// a script runs, ahead of its final response, so that the output is
+// captured with the configured limits and spilling as it arrives instead
+// of being held in memory whole on either side.
+type outputFrame struct {
+	// Output is "stdout" or "stderr"; it is empty in other frames.
+	Output string `json:"output"`
+	Data   []byte `json:"data"`
+}
+
+// capture writes the chunk to the capture of its stream.
+func (f *outputFrame) capture(stdout, stderr *capture) {
+	if f.Output == Stderr.String() {
+		stderr.Write(f.Data)
+	} else {
+		stdout.Write(f.Data)
+	}
+}
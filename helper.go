@@ -0,0 +1,60 @@
+package pyexec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// helperDir is a directory holding one of the embedded Python helpers,
+// written there the first time it is needed and kept for the lifetime of
+// the process. The directory is not removed on exit, nor by closing a Pool
+// or ForkServer that runs the helper: other executions may still use it,
+// and there is no hook to run at exit. It is readable by every user.
+type helperDir struct {
+	file   string
+	source []byte
+
+	mu   sync.Mutex
+	path string
+}
+
+// dir returns the directory holding the helper. The helper is written
+// again if it has gone missing, e.g. removed by a cleaner of old
+// temporary files.
+func (h *helperDir) dir() (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.path != "" {
+		if _, err := os.Stat(filepath.Join(h.path, h.file)); err == nil {
+			return h.path, nil
+		}
+		os.RemoveAll(h.path)
+		h.path = ""
+	}
+	dir, err := os.MkdirTemp("", "pyexec-helpers-*")
+	if err == nil {
+		err = os.Chmod(dir, 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, h.file), h.source, 0o644)
+	}
+	if err != nil {
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+		return "", fmt.Errorf("failed to write helper %s: %w", h.file, err)
+	}
+	h.path = dir
+	return dir, nil
+}
+
+// filePath returns the path of the helper.
+func (h *helperDir) filePath() (string, error) {
+	dir, err := h.dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, h.file), nil
+}
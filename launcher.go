@@ -0,0 +1,72 @@
+package pyexec
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+)
+
+//go:embed launcher.py
+var launcherSource []byte
+
+// launcher holds launcher.py, which runs from a file rather than with -c so
+// that its source does not end up in every command line and log.
+var launcher = &helperDir{file: "pyexec_launcher.py", source: launcherSource}
+
+// launcherSettings mirrors the settings document read by launcher.py.
+type launcherSettings struct {
+	Rlimits map[string][2]uint64 `json:"rlimits,omitempty"`
+	// Loopback brings up the loopback interface of a new network
+	// namespace.
+	Loopback bool              `json:"loopback,omitempty"`
+	Landlock *landlockSettings `json:"landlock,omitempty"`
+	// Subreaper keeps the launcher running as the command's parent and a
+	// child subreaper, to kill what the command leaves behind.
+	Subreaper bool `json:"subreaper,omitempty"`
+}
+
+// launcherSettings returns the settings the launcher must apply before the
+// interpreter running code from scriptDir starts, or nil if it is not
+// needed.
+func (c *config) launcherSettings(scriptDir string) (*launcherSettings, error) {
+	landlock, err := c.landlockSettings(scriptDir)
+	if err != nil {
+		return nil, err
+	}
+	if c.subreaper {
+		if err := checkSubreaper(); err != nil {
+			return nil, err
+		}
+	}
+	s := launcherSettings{Rlimits: c.limits.rlimits(), Loopback: c.isolateNetwork, Landlock: landlock, Subreaper: c.subreaper}
+	if s.Rlimits == nil && !s.Loopback && s.Landlock == nil && !s.Subreaper {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// withLauncher rewrites the command line program args so it is started by
+// launcher.py, which applies settings and then executes program, an
+// absolute path. The launcher runs with the Python interpreter python.
+// When program is that interpreter, the launcher executes the interpreter
+// it runs in instead, bypassing wrappers such as pyenv shims, which a
+// confined script might not be allowed to run.
+func withLauncher(python, program string, args []string, settings *launcherSettings) (string, []string, error) {
+	pythonPath, err := exec.LookPath(python)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s: %w", ErrInterpreterNotFound, python, err)
+	}
+	launcherPath, err := launcher.filePath()
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return "", nil, err
+	}
+	if program == pythonPath {
+		program = ""
+	}
+	return pythonPath, append([]string{launcherPath, string(data), program}, args...), nil
+}
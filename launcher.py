@@ -0,0 +1,239 @@
+"""Launcher for pyexec scripts that need process settings applied first.
+
+Applies the settings given as a JSON document, such as resource limits, to
+its own process and then replaces itself with the real command, so the
+settings are in place before the interpreter that runs the script starts.
+With the subreaper setting it instead runs the command in a child and
+stays on as a child subreaper, so processes the script orphans are
+reparented to the launcher rather than init; once the command exits, those
+still running are killed and reaped, and the launcher exits the same way
+the command did.
+
+Usage: python pyexec_launcher.py <settings JSON> <program> <args>...
+
+An empty program stands for the interpreter running the launcher.
+"""
+import ctypes
+import fcntl
+import json
+import os
+import resource
+import signal
+import socket
+import struct
+import site
+import sys
+
+# Exit status when the settings cannot be applied, as used by shells for a
+# command that was found but could not be run.
+EXIT_SETUP_FAILED = 126
+
+# From <linux/sockios.h> and <net/if.h>.
+SIOCGIFFLAGS = 0x8913
+SIOCSIFFLAGS = 0x8914
+IFF_UP = 0x1
+
+
+def bring_up_loopback():
+    """Sets the IFF_UP flag of lo, which starts down in a new namespace."""
+    with socket.socket(socket.AF_INET, socket.SOCK_DGRAM) as s:
+        ifreq = struct.pack("16sH14x", b"lo", 0)
+        flags = struct.unpack("16sH", fcntl.ioctl(s, SIOCGIFFLAGS, ifreq)[:18])[1]
+        fcntl.ioctl(s, SIOCSIFFLAGS, struct.pack("16sH14x", b"lo", flags | IFF_UP))
+
+
+# Landlock system calls, numbered the same on every architecture, and the
+# filesystem access rights by ABI version, from <linux/landlock.h>.
+SYS_LANDLOCK_CREATE_RULESET = 444
+SYS_LANDLOCK_ADD_RULE = 445
+SYS_LANDLOCK_RESTRICT_SELF = 446
+LANDLOCK_RULE_PATH_BENEATH = 1
+
+# prctl options, from <linux/prctl.h>.
+PR_SET_PDEATHSIG = 1
+PR_SET_NO_NEW_PRIVS = 38
+PR_SET_CHILD_SUBREAPER = 36
+
+ACCESS_EXECUTE = 1 << 0
+ACCESS_WRITE_FILE = 1 << 1
+ACCESS_READ_FILE = 1 << 2
+ACCESS_READ_DIR = 1 << 3
+ACCESS_REFER = 1 << 13
+ACCESS_TRUNCATE = 1 << 14
+ACCESS_V1 = (1 << 13) - 1
+# Rights that apply to files rather than directories.
+ACCESS_FILE = ACCESS_EXECUTE | ACCESS_WRITE_FILE | ACCESS_READ_FILE | ACCESS_TRUNCATE
+ACCESS_READ = ACCESS_EXECUTE | ACCESS_READ_FILE | ACCESS_READ_DIR
+
+
+def interpreter_paths():
+    """Directories the running interpreter needs to read."""
+    paths = {sys.prefix, sys.base_prefix, sys.exec_prefix, sys.base_exec_prefix}
+    paths.update(site.getsitepackages())
+    paths.add(site.getusersitepackages())
+    paths.add(os.path.dirname(os.path.realpath(sys.executable)))
+    return paths
+
+
+def confine(landlock):
+    """Restricts the process and its future children to the given paths."""
+    libc = ctypes.CDLL(None, use_errno=True)
+
+    def syscall(*args):
+        ret = libc.syscall(*(ctypes.c_long(a) if isinstance(a, int) else a for a in args))
+        if ret < 0:
+            errno = ctypes.get_errno()
+            raise OSError(errno, os.strerror(errno))
+        return ret
+
+    handled = ACCESS_V1
+    if landlock["abi"] >= 2:
+        handled |= ACCESS_REFER
+    if landlock["abi"] >= 3:
+        handled |= ACCESS_TRUNCATE
+    rules = [(p, ACCESS_READ) for p in landlock.get("read") or []]
+    rules += [(p, ACCESS_READ) for p in interpreter_paths()]
+    rules += [(p, handled) for p in landlock.get("write") or []]
+
+    attr = ctypes.c_uint64(handled)  # struct landlock_ruleset_attr
+    ruleset = syscall(SYS_LANDLOCK_CREATE_RULESET, ctypes.byref(attr), ctypes.sizeof(attr), 0)
+    for path, access in rules:
+        try:
+            fd = os.open(path, os.O_PATH | os.O_CLOEXEC)
+        except OSError:
+            continue  # Paths that do not exist need no rule.
+        try:
+            if not os.path.isdir(path):
+                access &= ACCESS_FILE
+            # struct landlock_path_beneath_attr is packed: u64 then s32.
+            rule = ctypes.create_string_buffer(struct.pack("=Qi", access & handled, fd))
+            syscall(SYS_LANDLOCK_ADD_RULE, ruleset, LANDLOCK_RULE_PATH_BENEATH, rule, 0)
+        finally:
+            os.close(fd)
+    prctl(PR_SET_NO_NEW_PRIVS, 1)
+    syscall(SYS_LANDLOCK_RESTRICT_SELF, ruleset, 0)
+    os.close(ruleset)
+
+
+def prctl(option, value):
+    libc = ctypes.CDLL(None, use_errno=True)
+    if libc.prctl(option, value, 0, 0, 0) != 0:
+        errno = ctypes.get_errno()
+        raise OSError(errno, os.strerror(errno))
+
+
+# Signals the supervising launcher survives. Sent to the process group,
+# they reach the command too, and the launcher has to outlive it to clean
+# up after it.
+SURVIVED_SIGNALS = (
+    signal.SIGHUP, signal.SIGINT, signal.SIGQUIT, signal.SIGTERM, signal.SIGUSR1, signal.SIGUSR2,
+)
+
+
+def setup(step, func, *args):
+    """Runs one setup step, exiting with EXIT_SETUP_FAILED if it fails."""
+    try:
+        func(*args)
+    except (OSError, ValueError) as e:
+        print("pyexec launcher: failed to %s: %s" % (step, e), file=sys.stderr, flush=True)
+        os._exit(EXIT_SETUP_FAILED)
+
+
+def execute(argv, landlock):
+    """Applies the Landlock rules, if any, and replaces the process with
+    argv."""
+    if landlock:
+        setup("apply landlock rules", confine, landlock)
+    os.execv(argv[0], argv)
+
+
+def children():
+    """Returns the pids of the processes whose parent is the launcher."""
+    me = os.getpid()
+    pids = []
+    for name in os.listdir("/proc"):
+        if not name.isdigit():
+            continue
+        try:
+            with open("/proc/%s/stat" % name, "rb") as f:
+                stat = f.read()
+        except OSError:
+            continue
+        # The command name may contain spaces and parentheses, so fields
+        # are counted from the last ')'; the parent pid is the second.
+        if int(stat[stat.rindex(b")") + 2 :].split()[1]) == me:
+            pids.append(int(name))
+    return pids
+
+
+def supervise(argv, landlock):
+    """Runs argv in a child as a child subreaper and returns its wait
+    status once it and everything it left behind are gone. The launcher
+    itself is not confined, so it can find the processes to kill."""
+    setup("become a child subreaper", prctl, PR_SET_CHILD_SUBREAPER, 1)
+    for sig in SURVIVED_SIGNALS:
+        signal.signal(sig, lambda *_: None)
+    launcher = os.getpid()
+    pid = os.fork()
+    if pid == 0:
+        try:
+            for sig in SURVIVED_SIGNALS:
+                signal.signal(sig, signal.SIG_DFL)
+            # The command must not outlive the launcher, which is killed
+            # with the Go process or at the end of a stop sequence.
+            setup("set the parent death signal", prctl, PR_SET_PDEATHSIG, signal.SIGKILL)
+            if os.getppid() == launcher:
+                execute(argv, landlock)
+        except OSError as e:
+            print("pyexec launcher: failed to execute %s: %s" % (argv[0], e), file=sys.stderr, flush=True)
+        finally:
+            os._exit(EXIT_SETUP_FAILED)
+
+    status = None
+    while True:
+        if status is not None:
+            # Killing a process reparents its children here before it can
+            # be waited for, so they are found on the next round.
+            for child in children():
+                try:
+                    os.kill(child, signal.SIGKILL)
+                except ProcessLookupError:
+                    pass
+        try:
+            child, child_status = os.wait()
+        except ChildProcessError:
+            return status
+        if child == pid:
+            status = child_status
+
+
+def exit_as(status):
+    """Exits with the wait status of the command."""
+    if os.WIFSIGNALED(status):
+        sig = os.WTERMSIG(status)
+        # The signal is passed on, without a core dump of the launcher.
+        # Python ignores some signals, such as SIGPIPE, by default.
+        resource.setrlimit(resource.RLIMIT_CORE, (0, resource.getrlimit(resource.RLIMIT_CORE)[1]))
+        if sig != signal.SIGKILL:
+            signal.signal(sig, signal.SIG_DFL)
+        os.kill(os.getpid(), sig)
+        return 128 + sig
+    return os.WEXITSTATUS(status)
+
+
+def main():
+    settings = json.loads(sys.argv[1])
+    argv = sys.argv[2:]
+    if not argv[0]:
+        argv[0] = sys.executable
+    if settings.get("loopback"):
+        setup("bring up loopback", bring_up_loopback)
+    for name, (soft, hard) in (settings.get("rlimits") or {}).items():
+        setup("set " + name, resource.setrlimit, getattr(resource, name), (soft, hard))
+    if settings.get("subreaper"):
+        return exit_as(supervise(argv, settings.get("landlock")))
+    execute(argv, settings.get("landlock"))
+
+
+if __name__ == "__main__":
+    sys.exit(main())
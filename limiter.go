@@ -0,0 +1,178 @@
+package pyexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Limiter bounds how many scripts run at once, overall and per script.
+// Executions over a limit wait for a slot in first-come, first-served
+// order, until their context is done; an execution waiting for a busy
+// script does not hold up those of other scripts. Share one Limiter
+// between executors with WithLimiter. It is safe for concurrent use.
+type Limiter struct {
+	mu      sync.Mutex
+	cfg     limiterConfig
+	running int
+	// perScript counts the running executions of each script.
+	perScript map[string]int
+	queue     []*limiterWaiter
+}
+
+type limiterConfig struct {
+	max     int
+	scripts map[string]int
+}
+
+// LimiterOption configures a Limiter.
+type LimiterOption func(*limiterConfig)
+
+// WithMaxConcurrent sets how many scripts may run at once in total.
+// The default, 0, is unlimited.
+func WithMaxConcurrent(n int) LimiterOption {
+	return func(c *limiterConfig) {
+		c.max = n
+	}
+}
+
+// WithScriptConcurrency sets how many executions of scriptName, the name
+// as passed to Run, may run at once. 0 removes the limit.
+func WithScriptConcurrency(scriptName string, n int) LimiterOption {
+	return func(c *limiterConfig) {
+		if c.scripts == nil {
+			c.scripts = make(map[string]int)
+		}
+		c.scripts[scriptName] = n
+	}
+}
+
+// WithSingleton allows only one execution of scriptName at a time; others
+// wait for it to finish.
+func WithSingleton(scriptName string) LimiterOption {
+	return WithScriptConcurrency(scriptName, 1)
+}
+
+// NewLimiter returns a Limiter configured by opts.
+func NewLimiter(opts ...LimiterOption) *Limiter {
+	l := &Limiter{perScript: make(map[string]int)}
+	for _, opt := range opts {
+		opt(&l.cfg)
+	}
+	return l
+}
+
+// LimiterStats is a snapshot of a Limiter's occupancy.
+type LimiterStats struct {
+	// Running is the number of executions holding a slot.
+	Running int
+	// Queued is the number of executions waiting for a slot.
+	Queued int
+	// Scripts breaks the counts down by script, for scripts with running
+	// or queued executions.
+	Scripts map[string]ScriptStats
+}
+
+// ScriptStats are the occupancy counts of one script.
+type ScriptStats struct {
+	Running int
+	Queued  int
+}
+
+// Stats returns the current occupancy of the limiter.
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := LimiterStats{Running: l.running, Queued: len(l.queue), Scripts: make(map[string]ScriptStats)}
+	for script, n := range l.perScript {
+		stats.Scripts[script] = ScriptStats{Running: n}
+	}
+	for _, w := range l.queue {
+		s := stats.Scripts[w.script]
+		s.Queued++
+		stats.Scripts[w.script] = s
+	}
+	return stats
+}
+
+// limiterWaiter is an execution waiting for a slot. ready is closed once
+// it has been granted one.
+type limiterWaiter struct {
+	script string
+	ready  chan struct{}
+}
+
+// acquire waits for a slot to run scriptName and returns the function
+// that gives it back. A nil Limiter admits everything.
+func (l *Limiter) acquire(ctx context.Context, scriptName string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	w := &limiterWaiter{script: scriptName, ready: make(chan struct{})}
+	release = sync.OnceFunc(func() { l.release(scriptName) })
+
+	l.mu.Lock()
+	l.queue = append(l.queue, w)
+	l.grant()
+	queued := len(l.queue)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return release, nil
+	default:
+	}
+	GetZlog().Info().Str("script", scriptName).Int("queued", queued).Msg("Waiting for an execution slot")
+	select {
+	case <-w.ready:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	i := slices.Index(l.queue, w)
+	if i >= 0 {
+		l.queue = slices.Delete(l.queue, i, i+1)
+	}
+	l.mu.Unlock()
+	if i < 0 {
+		// The slot was granted while giving up.
+		release()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("gave up waiting for an execution slot for '%s': %w: %w", scriptName, ErrTimeout, ctx.Err())
+	}
+	return nil, fmt.Errorf("gave up waiting for an execution slot for '%s': %w", scriptName, ctx.Err())
+}
+
+// release gives back a slot of scriptName and admits whoever fits.
+func (l *Limiter) release(scriptName string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running--
+	if l.perScript[scriptName]--; l.perScript[scriptName] == 0 {
+		delete(l.perScript, scriptName)
+	}
+	l.grant()
+}
+
+// grant hands slots to queued waiters in order, skipping those whose
+// script is at its limit. l.mu must be held.
+func (l *Limiter) grant() {
+	for i := 0; i < len(l.queue); {
+		if l.cfg.max > 0 && l.running >= l.cfg.max {
+			return
+		}
+		w := l.queue[i]
+		if n := l.cfg.scripts[w.script]; n > 0 && l.perScript[w.script] >= n {
+			i++
+			continue
+		}
+		l.running++
+		l.perScript[w.script]++
+		l.queue = slices.Delete(l.queue, i, i+1)
+		close(w.ready)
+	}
+}
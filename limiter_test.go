@@ -0,0 +1,115 @@
+package pyexec
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("FIFO", func(t *testing.T) {
+		l := NewLimiter(WithMaxConcurrent(1))
+		release, err := l.acquire(context.Background(), "a.py")
+		if err != nil {
+			t.Fatalf("acquire failed: %v", err)
+		}
+		var mu sync.Mutex
+		var order []int
+		var wg sync.WaitGroup
+		for i := range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r, err := l.acquire(context.Background(), "a.py")
+				if err != nil {
+					t.Errorf("acquire failed: %v", err)
+					return
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				r()
+			}()
+			// Queue the waiters one after the other.
+			for l.Stats().Queued != i+1 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		if s := l.Stats(); s.Running != 1 || s.Scripts["a.py"] != (ScriptStats{Running: 1, Queued: 3}) {
+			t.Errorf("Unexpected stats %+v", s)
+		}
+		release()
+		wg.Wait()
+		if len(order) != 3 || order[0] != 0 || order[1] != 1 || order[2] != 2 {
+			t.Errorf("Expected waiters to run in order, got %v", order)
+		}
+		if s := l.Stats(); s.Running != 0 || s.Queued != 0 || len(s.Scripts) != 0 {
+			t.Errorf("Expected an idle limiter, got %+v", s)
+		}
+	})
+
+	t.Run("PerScript", func(t *testing.T) {
+		l := NewLimiter(WithMaxConcurrent(3), WithSingleton("single.py"))
+		release, err := l.acquire(context.Background(), "single.py")
+		if err != nil {
+			t.Fatalf("acquire failed: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := l.acquire(ctx, "single.py"); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected a second singleton to time out, got %v", err)
+		}
+		// Other scripts are not held up by the busy one.
+		other, err := l.acquire(context.Background(), "other.py")
+		if err != nil {
+			t.Fatalf("acquire failed: %v", err)
+		}
+		other()
+		release()
+		if s := l.Stats(); s.Running != 0 || s.Queued != 0 {
+			t.Errorf("Expected an idle limiter, got %+v", s)
+		}
+	})
+
+	t.Run("Executor", func(t *testing.T) {
+		script := writeScript(t, "exclusive.py", `import os, sys, time
+lock = os.path.join(os.path.dirname(__file__), "lock")
+fd = os.open(lock, os.O_CREAT | os.O_EXCL)
+time.sleep(0.2)
+os.close(fd)
+os.remove(lock)
+`)
+		l := NewLimiter(WithSingleton(script))
+		exe := NewExecutor(WithLimiter(l))
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := exe.Run(context.Background(), script, nil); err != nil {
+					t.Errorf("Expected runs not to overlap, got %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		x, err := exe.Start(context.Background(), script, nil)
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := exe.Run(ctx, script, nil); !errors.Is(err, ErrTimeout) || !strings.Contains(err.Error(), "execution slot") {
+			t.Errorf("Expected the wait for a slot to time out, got %v", err)
+		}
+		if _, err := x.Wait(); err != nil {
+			t.Errorf("Wait failed: %v", err)
+		}
+		if s := l.Stats(); s.Running != 0 || s.Queued != 0 {
+			t.Errorf("Expected an idle limiter, got %+v", s)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package pyexec
+
+import (
+	"os"
+	"time"
+)
+
+// Limits are resource limits applied with setrlimit to a script's process
+// before the interpreter starts. Zero fields are not limited. Processes
+// the script starts inherit the limits. Limits are only supported on Unix.
+type Limits struct {
+	// CPUTime limits the CPU time (RLIMIT_CPU), rounded up to whole
+	// seconds. The script is sent SIGXCPU when it is used up.
+	CPUTime time.Duration
+	// Memory limits the address space in bytes (RLIMIT_AS). Allocations
+	// beyond it fail, which Python raises as MemoryError.
+	Memory int64
+	// OpenFiles limits the number of open file descriptors (RLIMIT_NOFILE).
+	OpenFiles int
+	// Processes limits the number of processes (RLIMIT_NPROC). The kernel
+	// counts every process of the script's user, not only the script's, so
+	// this is only meaningful when scripts run as a dedicated user.
+	Processes int
+	// FileSize limits the size of files the script writes (RLIMIT_FSIZE).
+	FileSize int64
+}
+
+// rlimits returns the limits as setrlimit arguments keyed by the names of
+// Python's resource module constants, or nil if nothing is limited.
+func (l Limits) rlimits() map[string][2]uint64 {
+	m := make(map[string][2]uint64)
+	if l.CPUTime > 0 {
+		secs := uint64((l.CPUTime + time.Second - 1) / time.Second)
+		// The hard limit is a second higher so the script gets SIGXCPU,
+		// which it can tell apart from other kills, before SIGKILL.
+		m["RLIMIT_CPU"] = [2]uint64{secs, secs + 1}
+	}
+	if l.Memory > 0 {
+		m["RLIMIT_AS"] = [2]uint64{uint64(l.Memory), uint64(l.Memory)}
+	}
+	if l.OpenFiles > 0 {
+		m["RLIMIT_NOFILE"] = [2]uint64{uint64(l.OpenFiles), uint64(l.OpenFiles)}
+	}
+	if l.Processes > 0 {
+		m["RLIMIT_NPROC"] = [2]uint64{uint64(l.Processes), uint64(l.Processes)}
+	}
+	if l.FileSize > 0 {
+		m["RLIMIT_FSIZE"] = [2]uint64{uint64(l.FileSize), uint64(l.FileSize)}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// reason guesses which limit, if any, made the script fail, from the
+// signal that terminated it or the Python error it printed. Only limits
+// that are set are considered.
+func (l Limits) reason(res *Result) TerminationReason {
+	signaled := func(sig os.Signal) bool { return res.Signal != nil && res.Signal == sig }
+	has := res.stderrContains
+	switch {
+	case l.CPUTime > 0 && (signaled(sigCPULimit) || (signaled(os.Kill) && res.UserTime+res.SystemTime >= l.CPUTime)):
+		return ReasonCPULimit
+	case l.FileSize > 0 && (signaled(sigFileSizeLimit) || has("[Errno 27]")):
+		return ReasonFileSizeLimit
+	case l.Memory > 0 && (has("MemoryError") || has("[Errno 12]")):
+		return ReasonMemoryLimit
+	case l.OpenFiles > 0 && has("[Errno 24]"):
+		return ReasonOpenFilesLimit
+	case l.Processes > 0 && has("[Errno 11]"):
+		return ReasonProcessLimit
+	}
+	return ReasonNone
+}
+
+// networkBlocked reports whether the script failed reaching the network,
+// judging by the Python error it printed.
+func networkBlocked(res *Result) bool {
+	for _, msg := range []string{"[Errno 101]", "Network is unreachable", "Temporary failure in name resolution"} {
+		if res.stderrContains(msg) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package pyexec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		limits Limits
+		reason TerminationReason
+	}{
+		{
+			name:   "Memory",
+			body:   "data = bytearray(1 << 30)\n",
+			limits: Limits{Memory: 256 << 20},
+			reason: ReasonMemoryLimit,
+		},
+		{
+			name:   "CPUTime",
+			body:   "while True:\n    pass\n",
+			limits: Limits{CPUTime: time.Second},
+			reason: ReasonCPULimit,
+		},
+		{
+			name:   "OpenFiles",
+			body:   "import os\nfiles = [open(os.devnull) for _ in range(100)]\n",
+			limits: Limits{OpenFiles: 32},
+			reason: ReasonOpenFilesLimit,
+		},
+		{
+			name:   "FileSize",
+			body:   "import os, tempfile\nwith tempfile.TemporaryFile() as f:\n    f.write(b'x' * (2 << 20))\n    f.flush()\n",
+			limits: Limits{FileSize: 1 << 20},
+			reason: ReasonFileSizeLimit,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			script := writeScript(t, "greedy.py", tc.body)
+			res, err := NewExecutor(WithLimits(tc.limits)).Run(context.Background(), script, nil, WithTimeout(30*time.Second))
+			if err == nil {
+				t.Fatal("Expected the script to fail")
+			}
+			if res.Reason != tc.reason {
+				t.Errorf("Expected reason %q, got %q (signal %v, stderr %q)", tc.reason, res.Reason, res.Signal, res.Stderr)
+			}
+			if !strings.Contains(err.Error(), string(tc.reason)) {
+				t.Errorf("Expected the error to mention %q, got %v", tc.reason, err)
+			}
+		})
+	}
+
+	t.Run("LongStderr", func(t *testing.T) {
+		// The error is printed after more output than is kept in memory.
+		script := writeScript(t, "noisy.py", "import os, sys\nsys.stderr.write('log line\\n' * 10000)\nfiles = [open(os.devnull) for _ in range(100)]\n")
+		exe := NewExecutor(WithLimits(Limits{OpenFiles: 32}))
+		for name, opts := range map[string][]Option{
+			"Spilled":   {WithSpill(1000, t.TempDir())},
+			"Truncated": {WithOutputLimit(0, 1000)},
+		} {
+			res, err := exe.Run(context.Background(), script, nil, opts...)
+			if err == nil {
+				t.Fatalf("%s: expected the script to fail", name)
+			}
+			defer res.Close()
+			if res.Reason != ReasonOpenFilesLimit {
+				t.Errorf("%s: expected reason %q, got %q", name, ReasonOpenFilesLimit, res.Reason)
+			}
+		}
+	})
+
+	t.Run("PerScript", func(t *testing.T) {
+		script := writeScript(t, "nofile.py", "import resource\nprint(resource.getrlimit(resource.RLIMIT_NOFILE))\n")
+		other := writeScript(t, "other.py", "import resource\nprint(resource.getrlimit(resource.RLIMIT_NOFILE))\n")
+		e := NewExecutor(
+			WithLimits(Limits{OpenFiles: 100}),
+			WithScriptOptions(script, WithLimits(Limits{OpenFiles: 50})),
+		)
+		for _, tc := range []struct {
+			script string
+			opts   []Option
+			want   string
+		}{
+			{script, nil, "(50, 50)"},
+			{other, nil, "(100, 100)"},
+			{script, []Option{WithLimits(Limits{OpenFiles: 70})}, "(70, 70)"},
+		} {
+			res, err := e.Run(context.Background(), tc.script, nil, tc.opts...)
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if got := strings.TrimSpace(string(res.Stdout)); got != tc.want {
+				t.Errorf("Expected limit %s, got %s", tc.want, got)
+			}
+		}
+	})
+
+	t.Run("InvalidLimit", func(t *testing.T) {
+		// No process may raise RLIMIT_NOFILE above fs.nr_open.
+		res, err := NewExecutor(WithLimits(Limits{OpenFiles: 1 << 30})).Run(context.Background(), "test_script.py", nil)
+		var exitErr *ScriptExitError
+		if !errors.As(err, &exitErr) || !strings.Contains(string(res.Stderr), "pyexec launcher") {
+			t.Errorf("Expected the launcher to report the failure, got %v", err)
+		}
+	})
+
+	t.Run("LauncherFile", func(t *testing.T) {
+		exe := NewExecutor(WithLimits(Limits{OpenFiles: 64}))
+		c := exe.configFor("test_script.py", nil)
+		cmd, err := c.command(context.Background(), "test_script.py", nil)
+		if err != nil {
+			t.Fatalf("command failed: %v", err)
+		}
+		if line := cmd.String(); strings.Contains(line, "import") || !strings.HasSuffix(line, "test_script.py") {
+			t.Errorf("Expected the launcher to run from a file, got %q", line)
+		}
+		// The launcher is written again if a cleaner of old temporary
+		// files removed it.
+		if err := os.Remove(cmd.Args[1]); err != nil {
+			t.Fatalf("Failed to remove launcher: %v", err)
+		}
+		if _, err := exe.Run(context.Background(), "test_script.py", nil); err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,468 @@
+package pyexec
+
+import (
+	"io"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Backend selects how an Executor launches a script.
+type Backend int
+
+const (
+	// BackendPython runs the script with a Python interpreter directly.
+	BackendPython Backend = iota
+	// BackendUV runs the script through `uv run`, which manages the Python
+	// environment and the script's dependencies.
+	BackendUV
+)
+
+// String returns the backend name as used in logs.
+func (b Backend) String() string {
+	switch b {
+	case BackendPython:
+		return "python"
+	case BackendUV:
+		return "uv"
+	default:
+		return "unknown"
+	}
+}
+
+// EnvMode controls which environment variables of the current process a
+// script inherits.
+type EnvMode int
+
+const (
+	// EnvInherit passes the whole environment of the current process.
+	EnvInherit EnvMode = iota
+	// EnvAllowlist passes only the variables named with WithEnvAllowlist.
+	EnvAllowlist
+	// EnvClean passes nothing from the current process. Note that PATH and
+	// HOME are not set either unless given with WithEnv.
+	EnvClean
+)
+
+// defaultInjectedEnv is set for every script unless replaced with
+// WithInjectedEnv, so output is unbuffered and decodes the same regardless
+// of the host's locale.
+var defaultInjectedEnv = []string{"PYTHONUNBUFFERED=1", "PYTHONIOENCODING=utf-8"}
+
+// defaultGracePeriod is the default wait after each signal of the stop
+// sequence before escalating to the next one.
+const defaultGracePeriod = 5 * time.Second
+
+// config holds everything that determines how a script is launched.
+// An Executor keeps one as its defaults; each call works on a copy with
+// the call's own options applied.
+type config struct {
+	backend     Backend
+	interpreter string
+	scriptDirs  []string
+	env         []string
+	envMode     EnvMode
+	envAllow    []string
+	injectedEnv []string
+	dir         string
+	timeout     time.Duration
+	stdin       io.Reader
+	stdinData   []byte
+	stdinPipe   bool
+	subreaper   bool
+	stdout      io.Writer
+	stderr      io.Writer
+	// Output capture limits, indexed by Stream, and spilling to disk.
+	outputLimits [2]int64
+	spillAt      int64
+	spillDir     string
+	// Line decoration used by Stream.
+	lineHandler  LineHandler
+	stdoutPrefix string
+	stderrPrefix string
+	timeLayout   string
+	maxLine      int
+	// Grace periods of the stop sequence, after SIGINT and after SIGTERM.
+	interruptGrace time.Duration
+	terminateGrace time.Duration
+	limits         Limits
+	isolateNetwork bool
+	confinement    *FSConfinement
+	credential     *Credential
+	workspace      *Workspace
+	limiter        *Limiter
+	idleTimeout    time.Duration
+	pty            *PTY
+	sampleInterval time.Duration
+	resultPipe     bool
+	// jsonMode, jsonBegin and jsonEnd select what RunJSON decodes.
+	jsonMode  jsonMode
+	jsonBegin string
+	jsonEnd   string
+	// execDir is the directory created for an execution with a workspace
+	// or confinement.
+	execDir *execDir
+	// shimHelper is the helper a pool worker or fork server runs, which a
+	// confined interpreter must be able to read.
+	shimHelper *helperDir
+	// scriptOpts holds options applied only to the named scripts.
+	scriptOpts map[string][]Option
+}
+
+// clone returns a copy of c whose slices can be appended to without
+// affecting c.
+func (c config) clone() config {
+	c.scriptDirs = slices.Clip(c.scriptDirs)
+	c.env = slices.Clip(c.env)
+	c.envAllow = slices.Clip(c.envAllow)
+	c.injectedEnv = slices.Clip(c.injectedEnv)
+	c.scriptOpts = maps.Clone(c.scriptOpts)
+	return c
+}
+
+// forScript applies the options registered for scriptName with
+// WithScriptOptions.
+func (c *config) forScript(scriptName string) {
+	for _, opt := range c.scriptOpts[scriptName] {
+		opt(c)
+	}
+}
+
+// Option configures an Executor, or a single call when passed to one of
+// its methods.
+type Option func(*config)
+
+// WithBackend selects the backend used to launch scripts.
+// The default is BackendPython.
+func WithBackend(b Backend) Option {
+	return func(c *config) {
+		c.backend = b
+	}
+}
+
+// WithInterpreter sets the Python interpreter, either a command name looked
+// up in PATH or a path to an executable. For BackendPython it replaces the
+// PYTHON_COMMAND / python3 / python lookup; for BackendUV it is passed to
+// `uv run --python`.
+func WithInterpreter(interpreter string) Option {
+	return func(c *config) {
+		c.interpreter = interpreter
+	}
+}
+
+// WithScriptDirs adds directories that are searched for scripts before the
+// ones listed in PYEXEC_SCRIPT_DIRS.
+func WithScriptDirs(dirs ...string) Option {
+	return func(c *config) {
+		c.scriptDirs = append(c.scriptDirs, dirs...)
+	}
+}
+
+// WithEnv adds environment variables, in "KEY=value" form, on top of the
+// inherited and injected ones. Later entries win.
+func WithEnv(env ...string) Option {
+	return func(c *config) {
+		c.env = append(c.env, env...)
+	}
+}
+
+// WithEnvMode sets which variables of the current process the script
+// inherits. The default is EnvInherit.
+func WithEnvMode(mode EnvMode) Option {
+	return func(c *config) {
+		c.envMode = mode
+	}
+}
+
+// WithEnvAllowlist switches to EnvAllowlist and adds names to the list of
+// inherited variables. A name ending in "*" matches every variable with
+// that prefix, e.g. "LC_*".
+func WithEnvAllowlist(names ...string) Option {
+	return func(c *config) {
+		c.envMode = EnvAllowlist
+		c.envAllow = append(c.envAllow, names...)
+	}
+}
+
+// WithInjectedEnv replaces the variables set for every script, which
+// default to PYTHONUNBUFFERED=1 and PYTHONIOENCODING=utf-8. Call it with
+// no arguments to inject nothing.
+func WithInjectedEnv(env ...string) Option {
+	return func(c *config) {
+		c.injectedEnv = env
+	}
+}
+
+// WithWorkDir sets the working directory of the script.
+// By default scripts run in their own directory.
+func WithWorkDir(dir string) Option {
+	return func(c *config) {
+		c.dir = dir
+	}
+}
+
+// WithTimeout stops the script if it runs longer than d.
+// Zero, the default, means no timeout beyond the caller's context.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithIdleTimeout stops the script, as WithTimeout does, if it writes
+// nothing to stdout or stderr for d. The error then matches
+// ErrIdleTimeout and Result.Reason is ReasonIdleTimeout. Zero, the default,
+// means no idle timeout. Pools and fork servers ignore it.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.idleTimeout = d
+	}
+}
+
+// WithPTY runs scripts attached to a pseudo-terminal configured by p; see
+// PTY. Executions fail to start on platforms other than Linux. Pools and
+// fork servers ignore it.
+func WithPTY(p PTY) Option {
+	return func(c *config) {
+		c.pty = &p
+	}
+}
+
+// WithUsageSampling samples the resource usage of the script's process
+// tree from /proc every interval while it runs, and reports it in
+// Result.Usage (Linux only; elsewhere Usage stays nil). Pools and fork
+// servers ignore it.
+func WithUsageSampling(interval time.Duration) Option {
+	return func(c *config) {
+		c.sampleInterval = interval
+	}
+}
+
+// WithResultPipe gives the script a pipe of its own to report a structured
+// result on, so stdout is left to logs (Unix only). The pipe is passed as
+// an extra file descriptor, named by the PYEXEC_RESULT_FD environment
+// variable, and the bundled pyexec_result module, added to PYTHONPATH,
+// writes to it: pyexec_result.set_result(value) sends value as JSON. The
+// bytes written arrive in Result.Payload, and RunJSON decodes them instead
+// of stdout. Pools and fork servers ignore it.
+func WithResultPipe() Option {
+	return func(c *config) {
+		c.resultPipe = true
+	}
+}
+
+// WithGracePeriods sets how a script is stopped when its context is done
+// or its timeout expires: it is sent SIGINT, then SIGTERM once the
+// interrupt grace period has passed, then SIGKILL once the terminate grace
+// period has passed too. A zero period skips its signal, so two zeros kill
+// the script immediately. The defaults are 5 seconds each. Signals reach
+// the script's whole process group on Linux; other platforms can only kill.
+func WithGracePeriods(interrupt, terminate time.Duration) Option {
+	return func(c *config) {
+		c.interruptGrace = interrupt
+		c.terminateGrace = terminate
+	}
+}
+
+// WithLimits sets the resource limits of each execution, replacing any set
+// before. See Limits.
+func WithLimits(l Limits) Option {
+	return func(c *config) {
+		c.limits = l
+	}
+}
+
+// WithNetworkIsolation runs scripts in new user and network namespaces
+// (Linux only), where the only network interface is loopback: scripts can
+// talk to themselves over 127.0.0.1 but connecting anywhere else fails
+// with "Network is unreachable" and name resolution fails. Inside, the
+// script runs as root of its user namespace, which maps to the user of
+// the current process, or as the user set with WithCredential. With
+// BackendUV, `uv run` is passed --offline, so the environment must have
+// been resolved beforehand; see Executor.Prepare. Executions fail to start
+// on other platforms.
+func WithNetworkIsolation() Option {
+	return func(c *config) {
+		c.isolateNetwork = true
+	}
+}
+
+// WithFSConfinement confines scripts to the files allowed by fc; see
+// FSConfinement. Use it with WithScriptOptions to allow different paths
+// for different scripts. For pools and fork servers the rules apply to the
+// long-lived process and no temporary directory is created; a fork
+// server's scripts must be listed in Read.
+func WithFSConfinement(fc FSConfinement) Option {
+	fc.Read = slices.Clone(fc.Read)
+	fc.Write = slices.Clone(fc.Write)
+	return func(c *config) {
+		c.confinement = &fc
+	}
+}
+
+// WithCredential runs scripts as the given user and groups (Linux only),
+// typically one with fewer privileges than the current process, which must
+// be allowed to switch to it. LookupCredential builds one from a user
+// name. The script's directory and interpreter must be accessible to that
+// user. Executions fail to start on other platforms.
+func WithCredential(cred Credential) Option {
+	cred.Groups = slices.Clone(cred.Groups)
+	return func(c *config) {
+		c.credential = &cred
+	}
+}
+
+// WithWorkspace runs each execution in a fresh directory set up as
+// described by ws, instead of the script's directory or the one set with
+// WithWorkDir. It has no effect on pools and fork servers.
+func WithWorkspace(ws Workspace) Option {
+	ws.Inputs = maps.Clone(ws.Inputs)
+	return func(c *config) {
+		c.workspace = &ws
+	}
+}
+
+// WithLimiter makes executions wait for a slot from l before they start;
+// see Limiter. The wait is bounded by the caller's context, not by
+// WithTimeout, which only applies once the script runs. Pools and fork
+// servers are not limited.
+func WithLimiter(l *Limiter) Option {
+	return func(c *config) {
+		c.limiter = l
+	}
+}
+
+// WithScriptOptions registers options that apply only when scriptName,
+// exactly as passed to Run, Stream, Start, NewPool or ForkServer.Run, is
+// executed. They are applied after the executor's own options and before
+// the options of the call, so per-call options still win. Registering
+// options for the same script again adds to them.
+func WithScriptOptions(scriptName string, opts ...Option) Option {
+	return func(c *config) {
+		if c.scriptOpts == nil {
+			c.scriptOpts = make(map[string][]Option)
+		}
+		c.scriptOpts[scriptName] = append(slices.Clip(c.scriptOpts[scriptName]), opts...)
+	}
+}
+
+// WithSubreaper runs the script under a supervising launcher process that
+// is a child subreaper (Linux only), so descendants of the script that
+// outlive their parent are reparented to it instead of init, even if they
+// leave the script's process group. When the script exits, the launcher
+// kills and reaps them all before exiting itself; if the launcher is
+// killed too, at the end of a stop sequence, those seen by a scan of /proc
+// are killed. The current process is not affected. Pools and fork servers
+// ignore it, and Start fails on other platforms.
+func WithSubreaper() Option {
+	return func(c *config) {
+		c.subreaper = true
+	}
+}
+
+// WithStdin supplies the script's standard input. A reader can only be
+// consumed once, so pass it per call rather than to NewExecutor.
+// By default the script's stdin is empty.
+func WithStdin(r io.Reader) Option {
+	return func(c *config) {
+		c.stdin = r
+		c.stdinData = nil
+		c.stdinPipe = false
+	}
+}
+
+// WithStdinBytes supplies data as the script's standard input.
+// Unlike WithStdin it can be given to NewExecutor, since every call reads
+// the data from the start.
+func WithStdinBytes(data []byte) Option {
+	return func(c *config) {
+		c.stdin = nil
+		c.stdinData = data
+		c.stdinPipe = false
+	}
+}
+
+// WithStdinPipe makes Start connect the script's standard input to a pipe,
+// available from Execution.Stdin, so input can be written while the script
+// runs. Closing the pipe signals end of input.
+func WithStdinPipe() Option {
+	return func(c *config) {
+		c.stdin = nil
+		c.stdinData = nil
+		c.stdinPipe = true
+	}
+}
+
+// WithStdout sets where Stream writes the script's stdout lines.
+// The default is os.Stdout; nil discards them.
+func WithStdout(w io.Writer) Option {
+	return func(c *config) {
+		c.stdout = w
+	}
+}
+
+// WithStderr sets where Stream writes the script's stderr lines.
+// The default is os.Stderr; nil discards them.
+func WithStderr(w io.Writer) Option {
+	return func(c *config) {
+		c.stderr = w
+	}
+}
+
+// WithLineHandler makes Stream pass every output line to h instead of
+// writing it to the stdout and stderr writers.
+func WithLineHandler(h LineHandler) Option {
+	return func(c *config) {
+		c.lineHandler = h
+	}
+}
+
+// WithPrefixes sets the text Stream writes before each stdout and stderr
+// line. The defaults are "[stdout] " and "[stderr] "; use empty strings to
+// write lines unchanged.
+func WithPrefixes(stdout, stderr string) Option {
+	return func(c *config) {
+		c.stdoutPrefix = stdout
+		c.stderrPrefix = stderr
+	}
+}
+
+// WithTimestamps makes Stream start each written line with the current
+// time in the given layout, e.g. time.RFC3339. An empty layout, the
+// default, disables timestamps.
+func WithTimestamps(layout string) Option {
+	return func(c *config) {
+		c.timeLayout = layout
+	}
+}
+
+// WithMaxLineLength makes Stream deliver lines longer than n bytes in
+// n-byte pieces instead of waiting for the end of the line. By default
+// lines are delivered whole however long they are. The captured output in
+// the Result is complete either way.
+func WithMaxLineLength(n int) Option {
+	return func(c *config) {
+		c.maxLine = n
+	}
+}
+
+// WithOutputLimit caps how many bytes of stdout and stderr are captured in
+// the Result; zero means unlimited, the default. The script is not stopped
+// when a limit is reached: further output is read and dropped, and the
+// Result's StdoutTruncated or StderrTruncated flag is set.
+func WithOutputLimit(stdout, stderr int64) Option {
+	return func(c *config) {
+		c.outputLimits = [2]int64{stdout, stderr}
+	}
+}
+
+// WithSpill moves captured output to a temporary file in dir (os.TempDir()
+// if empty) once a stream exceeds threshold bytes. Spilled output is read
+// with Result.OpenStdout or OpenStderr, and the files are removed by
+// Result.Close.
+func WithSpill(threshold int64, dir string) Option {
+	return func(c *config) {
+		c.spillAt = threshold
+		c.spillDir = dir
+	}
+}
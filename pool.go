@@ -0,0 +1,248 @@
+package pyexec
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//go:embed pool_worker.py
+var poolWorkerSource []byte
+
+// poolWorkerHelper holds pool_worker.py, which runs from a file, like the
+// launcher.
+var poolWorkerHelper = &helperDir{file: "pyexec_pool_worker.py", source: poolWorkerSource}
+
+var (
+	// ErrPoolClosed is returned by Pool.Call after the pool has been closed.
+	ErrPoolClosed = errors.New("pool closed")
+	// ErrWorkerCrashed is returned by Pool.Call when the worker process
+	// died during the call. The worker is replaced on the next call.
+	ErrWorkerCrashed = errors.New("worker crashed")
+)
+
+// poolConfig holds the settings of a Pool.
+type poolConfig struct {
+	size        int
+	maxRequests int
+	entryPoint  string
+}
+
+// PoolOption configures a Pool.
+type PoolOption func(*poolConfig)
+
+// WithPoolSize sets how many workers the pool keeps, i.e. how many calls
+// can run at the same time. The default is 1.
+func WithPoolSize(n int) PoolOption {
+	return func(c *poolConfig) {
+		c.size = n
+	}
+}
+
+// WithMaxRequests makes a worker be replaced by a fresh process after it
+// has served n calls, bounding leaks in long-lived interpreters. Zero, the
+// default, keeps workers until they crash or the pool is closed.
+func WithMaxRequests(n int) PoolOption {
+	return func(c *poolConfig) {
+		c.maxRequests = n
+	}
+}
+
+// WithEntryPoint sets the name of the function a worker calls for every
+// request. The default is "main".
+func WithEntryPoint(name string) PoolOption {
+	return func(c *poolConfig) {
+		c.entryPoint = name
+	}
+}
+
+// Pool keeps long-lived Python workers for one script, so the interpreter
+// start-up and the script's imports are paid once per worker instead of
+// once per call.
+//
+// Each worker imports the script as a module (so its `if __name__ ==
+// "__main__":` block does not run) and, for every call, invokes the entry
+// point with sys.argv set as for a normal run. If the entry point accepts
+// an argument it is passed the argument list, sys.argv[1:]. Whatever it
+// prints is captured per call, and a non-None return value is JSON-encoded
+// into Result.Payload. Raising SystemExit sets the exit code as usual.
+type Pool struct {
+	cfg        config
+	pcfg       poolConfig
+	scriptName string
+	scriptPath string
+
+	// slots holds one entry per worker; nil means the worker is not
+	// running and is started on demand.
+	slots chan *worker
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewPool starts a pool of workers for scriptName, configured like the
+// executor's scripts. It waits for the workers to import the script, so an
+// import error or a missing entry point is reported here.
+func (e *Executor) NewPool(ctx context.Context, scriptName string, opts ...PoolOption) (*Pool, error) {
+	pcfg := poolConfig{size: 1, entryPoint: "main"}
+	for _, opt := range opts {
+		opt(&pcfg)
+	}
+	if pcfg.size < 1 {
+		return nil, fmt.Errorf("invalid pool size %d", pcfg.size)
+	}
+	c := e.configFor(scriptName, nil)
+	scriptPath, err := findScript(scriptName, c.scriptDirs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find python script: %w", err)
+	}
+
+	p := &Pool{
+		cfg:        c,
+		pcfg:       pcfg,
+		scriptName: scriptName,
+		scriptPath: scriptPath,
+		slots:      make(chan *worker, pcfg.size),
+		done:       make(chan struct{}),
+	}
+	for i := 0; i < pcfg.size; i++ {
+		w, err := p.startWorker(ctx)
+		if err != nil {
+			for ; i < pcfg.size; i++ {
+				p.slots <- nil
+			}
+			p.Close()
+			return nil, err
+		}
+		p.slots <- w
+	}
+	return p, nil
+}
+
+// Call runs the entry point in an idle worker, waiting for one if all are
+// busy; if ctx is done first, the error wraps ctx's error, and ErrTimeout
+// for a deadline, as Run's does. A worker that crashes, or is killed
+// because ctx is done or the executor's timeout expires, is replaced on
+// the next call.
+func (p *Pool) Call(ctx context.Context, args []Arg) (*Result, error) {
+	var w *worker
+	select {
+	case w = <-p.slots:
+	case <-p.done:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("gave up waiting for a worker for python script '%s': %w: %w", p.scriptName, ErrTimeout, ctx.Err())
+		}
+		return nil, fmt.Errorf("gave up waiting for a worker for python script '%s': %w", p.scriptName, ctx.Err())
+	}
+	select {
+	case <-p.done:
+		p.slots <- w
+		return nil, ErrPoolClosed
+	default:
+	}
+
+	ctx, cancel := p.cfg.withTimeout(ctx)
+	defer cancel()
+
+	if w == nil {
+		var err error
+		if w, err = p.startWorker(ctx); err != nil {
+			p.slots <- nil
+			return nil, err
+		}
+	}
+	res, err := w.call(ctx, &p.cfg, p.scriptName, args)
+	if w.broken || (p.pcfg.maxRequests > 0 && w.served >= p.pcfg.maxRequests) {
+		w.stop()
+		w = nil
+	}
+	p.slots <- w
+	return res, err
+}
+
+// Close stops all workers, waiting for calls in progress to finish.
+// Calls made after Close return ErrPoolClosed.
+func (p *Pool) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+		for i := 0; i < p.pcfg.size; i++ {
+			if w := <-p.slots; w != nil {
+				w.stop()
+			}
+		}
+	})
+	return nil
+}
+
+// worker is one long-lived Python process running pool_worker.py.
+type worker struct {
+	*shim
+	// served counts the calls handled; broken marks a worker that must
+	// not be reused.
+	served int
+	broken bool
+}
+
+// startWorker launches a worker and waits for it to import the script.
+func (p *Pool) startWorker(ctx context.Context) (*worker, error) {
+	what := fmt.Sprintf("worker for python script '%s'", p.scriptName)
+	s, err := startShim(ctx, &p.cfg, what, poolWorkerHelper, filepath.Dir(p.scriptPath), p.scriptPath, p.pcfg.entryPoint)
+	if err != nil {
+		return nil, err
+	}
+	return &worker{shim: s}, nil
+}
+
+// workerRequest and workerResponse mirror the frames of pool_worker.py.
+type workerRequest struct {
+	Argv []string `json:"argv"`
+}
+
+// Output is sent in outputFrames ahead of the workerResponse.
+type workerResponse struct {
+	outputFrame
+	ExitCode int     `json:"exit_code"`
+	Result   *string `json:"result"`
+}
+
+// call runs one request on the worker.
+func (w *worker) call(ctx context.Context, c *config, scriptName string, args []Arg) (*Result, error) {
+	start := time.Now()
+	w.served++
+	stdout, stderr := newCapture(c, Stdout), newCapture(c, Stderr)
+	var resp workerResponse
+	err := w.exchange(ctx, workerRequest{Argv: argv(args)}, func() error {
+		for {
+			resp = workerResponse{}
+			if err := readFrame(w.responses, &resp); err != nil || resp.Output == "" {
+				return err
+			}
+			resp.capture(stdout, stderr)
+		}
+	})
+	res := capturedResult(start, stdout, stderr)
+	if err != nil {
+		w.broken = true
+		res.Reason = c.terminationReason(ctx, res)
+		if ctx.Err() != nil {
+			return res, executionError(ctx, scriptName, w.cmd.Dir, res, err)
+		}
+		<-w.exited
+		return res, fmt.Errorf("%w: python script '%s': %v (%v)", ErrWorkerCrashed, scriptName, err, w.waitErr)
+	}
+
+	res.ExitCode = resp.ExitCode
+	if resp.Result != nil {
+		res.Payload = []byte(*resp.Result)
+	}
+	res.Reason = c.terminationReason(ctx, res)
+	if res.ExitCode != 0 {
+		return res, executionError(ctx, scriptName, w.cmd.Dir, res, nil)
+	}
+	return res, nil
+}
@@ -0,0 +1,201 @@
+package pyexec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const poolTestScript = `import os, sys
+
+calls = 0
+
+def main(argv):
+    global calls
+    calls += 1
+    if argv and argv[0] == "--crash":
+        os._exit(7)
+    if argv and argv[0] == "--fail":
+        print("bad input", file=sys.stderr)
+        sys.exit(4)
+    if argv and argv[0] == "--flood":
+        sys.stdout.buffer.write(b"x" * 1000000)
+        return
+    if argv and argv[0] == "--sleep":
+        import time
+        time.sleep(30)
+    print("working on", argv)
+    return {"pid": os.getpid(), "calls": calls, "argv": sys.argv[1:]}
+
+if __name__ == "__main__":
+    raise RuntimeError("must not run in a pool worker")
+`
+
+type poolReply struct {
+	Pid   int      `json:"pid"`
+	Calls int      `json:"calls"`
+	Argv  []string `json:"argv"`
+}
+
+func callPool(t *testing.T, p *Pool, args ...Arg) poolReply {
+	t.Helper()
+	res, err := p.Call(context.Background(), args)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	var reply poolReply
+	if err := json.Unmarshal(res.Payload, &reply); err != nil {
+		t.Fatalf("Failed to decode payload %q: %v", res.Payload, err)
+	}
+	return reply
+}
+
+func TestPool(t *testing.T) {
+	script := writeScript(t, "pooled.py", poolTestScript)
+	ctx := context.Background()
+
+	t.Run("ReusesWorker", func(t *testing.T) {
+		p, err := NewExecutor().NewPool(ctx, script)
+		if err != nil {
+			t.Fatalf("NewPool failed: %v", err)
+		}
+		defer p.Close()
+
+		res, err := p.Call(ctx, []Arg{{Key: "--name", Value: "a"}, {Key: "--flag"}})
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if string(res.Stdout) != "working on ['--name', 'a', '--flag']\n" {
+			t.Errorf("Unexpected stdout: %q", res.Stdout)
+		}
+		first := callPool(t, p)
+		second := callPool(t, p, Arg{Key: "--x"})
+		if first.Pid != second.Pid || second.Calls != first.Calls+1 {
+			t.Errorf("Expected the same worker to serve both calls, got %+v and %+v", first, second)
+		}
+		if len(second.Argv) != 1 || second.Argv[0] != "--x" {
+			t.Errorf("Expected sys.argv[1:] to be [--x], got %v", second.Argv)
+		}
+	})
+
+	t.Run("RecycleAfterMaxRequests", func(t *testing.T) {
+		p, err := NewExecutor().NewPool(ctx, script, WithMaxRequests(2))
+		if err != nil {
+			t.Fatalf("NewPool failed: %v", err)
+		}
+		defer p.Close()
+		a, b, c := callPool(t, p), callPool(t, p), callPool(t, p)
+		if a.Pid != b.Pid || b.Pid == c.Pid || c.Calls != 1 {
+			t.Errorf("Expected a new worker after two calls, got %+v, %+v, %+v", a, b, c)
+		}
+	})
+
+	t.Run("ExitAndCrash", func(t *testing.T) {
+		p, err := NewExecutor().NewPool(ctx, script)
+		if err != nil {
+			t.Fatalf("NewPool failed: %v", err)
+		}
+		defer p.Close()
+
+		res, err := p.Call(ctx, []Arg{{Key: "--fail"}})
+		var exitErr *ScriptExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode != 4 || string(res.Stderr) != "bad input\n" {
+			t.Errorf("Expected a ScriptExitError with code 4, got %v (stderr %q)", err, res.Stderr)
+		}
+
+		before := callPool(t, p)
+		if _, err := p.Call(ctx, []Arg{{Key: "--crash"}}); !errors.Is(err, ErrWorkerCrashed) {
+			t.Fatalf("Expected ErrWorkerCrashed, got %v", err)
+		}
+		after := callPool(t, p)
+		if after.Pid == before.Pid || after.Calls != 1 {
+			t.Errorf("Expected a fresh worker after the crash, got %+v then %+v", before, after)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		p, err := NewExecutor(WithTimeout(300*time.Millisecond)).NewPool(ctx, script)
+		if err != nil {
+			t.Fatalf("NewPool failed: %v", err)
+		}
+		defer p.Close()
+		if _, err := p.Call(ctx, []Arg{{Key: "--sleep"}}); !errors.Is(err, ErrTimeout) {
+			t.Fatalf("Expected ErrTimeout, got %v", err)
+		}
+		callPool(t, p)
+
+		// A deadline that passes while all workers are busy is a timeout
+		// too.
+		go p.Call(ctx, []Arg{{Key: "--sleep"}})
+		time.Sleep(100 * time.Millisecond)
+		waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		if _, err := p.Call(waitCtx, nil); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected ErrTimeout while waiting for a worker, got %v", err)
+		}
+	})
+
+	t.Run("OutputLimit", func(t *testing.T) {
+		p, err := NewExecutor(WithOutputLimit(10, 0)).NewPool(ctx, script)
+		if err != nil {
+			t.Fatalf("NewPool failed: %v", err)
+		}
+		defer p.Close()
+		res, err := p.Call(ctx, []Arg{{Key: "--flood"}})
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if string(res.Stdout) != "xxxxxxxxxx" || !res.StdoutTruncated {
+			t.Errorf("Expected 10 bytes of truncated stdout, got %d bytes, truncated %v", len(res.Stdout), res.StdoutTruncated)
+		}
+		callPool(t, p)
+	})
+
+	t.Run("MissingEntryPoint", func(t *testing.T) {
+		_, err := NewExecutor(WithStderr(nil)).NewPool(ctx, script, WithEntryPoint("nope"), WithPoolSize(2))
+		if err == nil || !strings.Contains(err.Error(), "AttributeError") {
+			t.Errorf("Expected an AttributeError from NewPool, got %v", err)
+		}
+	})
+
+	t.Run("ScriptOptions", func(t *testing.T) {
+		name := filepath.Base(script)
+		exe := NewExecutor(WithScriptOptions(name, WithScriptDirs(filepath.Dir(script))))
+		p, err := exe.NewPool(ctx, name)
+		if err != nil {
+			t.Fatalf("NewPool failed: %v", err)
+		}
+		defer p.Close()
+		callPool(t, p)
+	})
+
+	t.Run("CommandLine", func(t *testing.T) {
+		p, err := NewExecutor().NewPool(ctx, script)
+		if err != nil {
+			t.Fatalf("NewPool failed: %v", err)
+		}
+		defer p.Close()
+		w := <-p.slots
+		cmdline := w.cmd.String()
+		p.slots <- w
+		if strings.Contains(cmdline, "def main") || !strings.Contains(cmdline, poolWorkerHelper.file) {
+			t.Errorf("Expected the worker to run from %s, got %q", poolWorkerHelper.file, cmdline)
+		}
+		callPool(t, p)
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		p, err := NewExecutor().NewPool(ctx, script)
+		if err != nil {
+			t.Fatalf("NewPool failed: %v", err)
+		}
+		p.Close()
+		if _, err := p.Call(ctx, nil); !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("Expected ErrPoolClosed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+"""Worker shim for pyexec.Pool.
+
+Imports a script once, then calls its entry point for every request read
+from stdin. Requests and responses are frames of a 4-byte big-endian length
+followed by a JSON document. What the entry point prints is sent in output
+frames while it runs, ahead of the response, so it is never held in memory
+here.
+
+Usage: python -u pyexec_pool_worker.py <script path> <entry point name>
+"""
+import base64
+import contextlib
+import importlib.util
+import inspect
+import io
+import json
+import os
+import struct
+import sys
+import threading
+import traceback
+
+# Largest chunk of output sent in one frame.
+CHUNK_SIZE = 65536
+
+
+def recv(stream):
+    header = stream.read(4)
+    if len(header) < 4:
+        return None
+    (size,) = struct.unpack(">I", header)
+    return json.loads(stream.read(size))
+
+
+class Channel:
+    """Sends frames to Go; the script's threads may print concurrently."""
+
+    def __init__(self, stream):
+        self.stream = stream
+        self.lock = threading.Lock()
+
+    def send(self, message):
+        data = json.dumps(message).encode("utf-8")
+        with self.lock:
+            self.stream.write(struct.pack(">I", len(data)) + data)
+            self.stream.flush()
+
+
+class Output(io.RawIOBase):
+    """Sends what is written to it as output frames of one stream, until
+    the call it belongs to is done."""
+
+    def __init__(self, channel, name):
+        super().__init__()
+        self.channel = channel
+        self.name = name
+        self.done = False
+
+    def writable(self):
+        return True
+
+    def write(self, b):
+        if self.done:
+            return len(b)
+        data = bytes(b)
+        for i in range(0, len(data), CHUNK_SIZE):
+            chunk = base64.b64encode(data[i : i + CHUNK_SIZE]).decode("ascii")
+            self.channel.send({"output": self.name, "data": chunk})
+        return len(data)
+
+
+def output_stream(channel, name, errors):
+    """Returns a text stream like sys.stdout, with a binary buffer, that
+    sends its output to Go."""
+    return io.TextIOWrapper(
+        io.BufferedWriter(Output(channel, name)), encoding="utf-8", errors=errors, write_through=True
+    )
+
+
+def accepts_argument(func):
+    try:
+        params = inspect.signature(func).parameters.values()
+    except (TypeError, ValueError):
+        return False
+    return any(
+        p.kind in (p.POSITIONAL_ONLY, p.POSITIONAL_OR_KEYWORD, p.VAR_POSITIONAL)
+        for p in params
+    )
+
+
+def exit_code(code):
+    if code is None:
+        return 0
+    if isinstance(code, int):
+        return code
+    print(code, file=sys.stderr)
+    return 1
+
+
+def handle(channel, func, takes_argv, script, argv):
+    out = output_stream(channel, "stdout", "strict")
+    err = output_stream(channel, "stderr", "backslashreplace")
+    code, result = 0, None
+    sys.argv = [script] + argv
+    with contextlib.redirect_stdout(out), contextlib.redirect_stderr(err):
+        try:
+            value = func(argv) if takes_argv else func()
+            if value is not None:
+                result = json.dumps(value)
+        except SystemExit as e:
+            code = exit_code(e.code)
+        except BaseException:
+            traceback.print_exc()
+            code = 1
+    # Output written after the call, e.g. by threads it left running, is
+    # dropped rather than mixed into the next call's.
+    for stream in (out, err):
+        try:
+            stream.flush()
+        except Exception:
+            pass
+        stream.buffer.raw.done = True
+    return {"exit_code": code, "result": result}
+
+
+def unshadow_path():
+    # Run from a file, Python puts this file's directory first on sys.path;
+    # resolve imports from the working directory instead, as with -c.
+    if sys.path and sys.path[0] == os.path.dirname(os.path.abspath(__file__)):
+        sys.path[0] = ""
+
+
+def main():
+    script, entry = sys.argv[1], sys.argv[2]
+    unshadow_path()
+    requests = sys.stdin.buffer
+    channel = Channel(os.fdopen(os.dup(1), "wb"))
+    # From here on anything written to fd 1, e.g. by C extensions, goes to
+    # stderr instead of corrupting the protocol.
+    os.dup2(2, 1)
+    sys.stdin = open(os.devnull)
+    sys.path.insert(0, os.path.dirname(script))
+
+    try:
+        spec = importlib.util.spec_from_file_location("__pyexec_script__", script)
+        module = importlib.util.module_from_spec(spec)
+        spec.loader.exec_module(module)
+        func = getattr(module, entry)
+        takes_argv = accepts_argument(func)
+    except BaseException:
+        channel.send({"ready": False, "error": traceback.format_exc()})
+        return 1
+    channel.send({"ready": True})
+
+    while True:
+        request = recv(requests)
+        if request is None:
+            return 0
+        channel.send(handle(channel, func, takes_argv, script, request["argv"]))
+
+
+if __name__ == "__main__":
+    sys.exit(main())
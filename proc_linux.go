@@ -0,0 +1,198 @@
+package pyexec
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// setProcessGroup runs the command in its own process group and makes
+// context cancellation kill the whole group instead of only the direct
+// child, so processes spawned by the script are stopped as well. The
+// command is also killed if the thread that started it exits; the Go
+// runtime keeps its threads until the process exits, unless a goroutine
+// ends while locked to one, so scripts do not outlive the Go process.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.SysProcAttr.Pdeathsig = syscall.SIGKILL
+	cmd.Cancel = func() error {
+		// A negative pid addresses every process in the group.
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// signalProcess delivers sig to the command's whole process group, so
+// processes spawned by the script receive it too.
+func signalProcess(cmd *exec.Cmd, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return cmd.Process.Signal(sig)
+	}
+	return syscall.Kill(-cmd.Process.Pid, s)
+}
+
+// killProcessGroup kills whatever is left in the command's process group
+// after it has exited, such as children the script did not wait for.
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// Signals sent by the kernel when RLIMIT_CPU and RLIMIT_FSIZE are exceeded.
+var (
+	sigCPULimit      os.Signal = syscall.SIGXCPU
+	sigFileSizeLimit os.Signal = syscall.SIGXFSZ
+)
+
+// isolateNetwork starts the command in new user and network namespaces.
+// Unless the command has a credential, the current user becomes root of
+// the user namespace, which gives the launcher the capability to bring up
+// loopback in the network namespace.
+func isolateNetwork(cmd *exec.Cmd) error {
+	attr := cmd.SysProcAttr
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+	cred := attr.Credential
+	if cred == nil {
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+		return nil
+	}
+	// With a credential, its ids are mapped to themselves so the script
+	// runs as the same user inside the namespace as outside. Not being
+	// root there, it is given CAP_NET_ADMIN to bring up the loopback
+	// interface; the capability only extends to the new namespace.
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: int(cred.Uid), HostID: int(cred.Uid), Size: 1}}
+	gids := append([]uint32{cred.Gid}, cred.Groups...)
+	slices.Sort(gids)
+	attr.GidMappings = nil
+	for _, gid := range slices.Compact(gids) {
+		attr.GidMappings = append(attr.GidMappings, syscall.SysProcIDMap{ContainerID: int(gid), HostID: int(gid), Size: 1})
+	}
+	attr.GidMappingsEnableSetgroups = true
+	attr.AmbientCaps = append(attr.AmbientCaps, capNetAdmin)
+	return nil
+}
+
+// capNetAdmin is CAP_NET_ADMIN from <linux/capability.h>.
+const capNetAdmin = 12
+
+// setCredential makes the command run as cred.
+func setCredential(cmd *exec.Cmd, cred *Credential) error {
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: cred.UID, Gid: cred.GID, Groups: slices.Clone(cred.Groups)}
+	return nil
+}
+
+// openPTY opens a new pseudo-terminal of the given size and returns its
+// master and slave ends. Output post-processing is turned off, so newlines
+// written by the script are not translated to CRLF.
+func openPTY(rows, cols uint16) (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			master.Close()
+		}
+	}()
+	var unlock int32
+	var n uint32
+	if err = fileIoctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		return nil, nil, err
+	}
+	if err = fileIoctl(master, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		return nil, nil, err
+	}
+	slave, err = os.OpenFile("/dev/pts/"+strconv.FormatUint(uint64(n), 10), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var termios syscall.Termios
+	if err = fileIoctl(slave, syscall.TCGETS, unsafe.Pointer(&termios)); err == nil {
+		termios.Oflag &^= syscall.OPOST
+		err = fileIoctl(slave, syscall.TCSETS, unsafe.Pointer(&termios))
+	}
+	if err == nil {
+		ws := struct{ row, col, xpixel, ypixel uint16 }{rows, cols, 0, 0}
+		err = fileIoctl(slave, syscall.TIOCSWINSZ, unsafe.Pointer(&ws))
+	}
+	if err != nil {
+		slave.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+// fileIoctl performs an ioctl on f without taking it out of non-blocking
+// mode, as f.Fd() would.
+func fileIoctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setControllingTerminal starts the command in a new session whose
+// controlling terminal is its stdout, which must be a terminal. The
+// session's process group replaces the one set by setProcessGroup.
+func setControllingTerminal(cmd *exec.Cmd) {
+	cmd.SysProcAttr.Setpgid = false
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 1
+}
+
+// isTerminalClosed reports whether err is the error reading the master end
+// of a pseudo-terminal returns once every slave end is closed.
+func isTerminalClosed(err error) bool {
+	return errors.Is(err, syscall.EIO)
+}
+
+// sysLandlockCreateRuleset is the landlock_create_ruleset system call
+// number, the same on every architecture.
+const sysLandlockCreateRuleset = 444
+
+// landlockABI returns the Landlock ABI version supported by the kernel, or
+// 0 if Landlock is not available.
+func landlockABI() int {
+	const createRulesetVersion = 1 << 0
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, createRulesetVersion)
+	if errno != 0 {
+		return 0
+	}
+	return int(abi)
+}
+
+// exitSignal returns the signal that terminated the process, or nil.
+func exitSignal(state *os.ProcessState) os.Signal {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal()
+	}
+	return nil
+}
+
+// maxRSS returns the peak resident set size in bytes; Linux reports
+// ru_maxrss in kilobytes.
+func maxRSS(state *os.ProcessState) int64 {
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return ru.Maxrss * 1024
+	}
+	return 0
+}
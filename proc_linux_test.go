@@ -0,0 +1,412 @@
+package pyexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestCancelKillsProcessTree(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	script := writeScript(t, "spawner.py", fmt.Sprintf(`import subprocess, sys, time
+child = subprocess.Popen([sys.executable, "-c", "import time; time.sleep(30)"])
+with open(%q, "w") as f:
+    f.write(str(child.pid))
+time.sleep(30)
+`, pidFile))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for i := 0; i < 100; i++ {
+			if fileExists(pidFile) {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		cancel()
+	}()
+	if _, err := ExecutePythonScriptRealtimeContext(ctx, script, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected error to wrap context.Canceled, but got: %v", err)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("Child pid file not written: %v", err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	deadline := time.Now().Add(5 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Grandchild process %d is still running after cancellation", pid)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestCancelDoesNotWaitForEscapedOutput(t *testing.T) {
+	// The grandchild leaves the process group, so it survives the script
+	// and keeps the stdout pipe open.
+	script := writeScript(t, "escaper.py", `import subprocess, sys, time
+child = subprocess.Popen([sys.executable, "-c", "import time; time.sleep(8)"],
+                         start_new_session=True)
+print(child.pid, flush=True)
+time.sleep(30)
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res, err := NewExecutor().Run(ctx, script, nil)
+	if pid, _ := strconv.Atoi(strings.TrimSpace(string(res.Stdout))); pid > 0 {
+		defer syscall.Kill(pid, syscall.SIGKILL)
+	}
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("Run waited %v for output of a process outside the group", elapsed)
+	}
+}
+
+func TestResultSignal(t *testing.T) {
+	script := writeScript(t, "suicide.py", "import os, signal\nos.kill(os.getpid(), signal.SIGTERM)\n")
+	res, err := NewExecutor().Run(context.Background(), script, nil)
+	if err == nil {
+		t.Fatal("Expected an error for a script killed by a signal, but got nil")
+	}
+	if res.Signal != syscall.SIGTERM || res.ExitCode != -1 {
+		t.Errorf("Expected SIGTERM and exit code -1, got %v / %d", res.Signal, res.ExitCode)
+	}
+	var sigErr *ScriptSignalError
+	if !errors.As(err, &sigErr) || sigErr.Signal != syscall.SIGTERM {
+		t.Errorf("Expected a ScriptSignalError for SIGTERM, but got: %v", err)
+	}
+}
+
+func TestExecutionSignal(t *testing.T) {
+	script := writeScript(t, "interruptible.py", `import time
+try:
+    print("ready", flush=True)
+    time.sleep(30)
+except KeyboardInterrupt:
+    print("interrupted")
+    raise SystemExit(3)
+`)
+	ready := make(chan struct{}, 1)
+	x, err := Start(script, nil, WithLineHandler(func(stream Stream, line string) {
+		if line == "ready" {
+			ready <- struct{}{}
+		}
+	}))
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	<-ready
+	if err := x.Signal(syscall.SIGINT); err != nil {
+		t.Fatalf("Signal failed: %v", err)
+	}
+	res, err := x.Wait()
+	var exitErr *ScriptExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode != 3 {
+		t.Fatalf("Expected exit code 3 after SIGINT, got %v", err)
+	}
+	if !strings.Contains(string(res.Stdout), "interrupted") {
+		t.Errorf("Expected the script to handle KeyboardInterrupt, stdout %q", res.Stdout)
+	}
+
+	sleeper := writeScript(t, "killed.py", "import time\ntime.sleep(30)\n")
+	x, err = Start(sleeper, nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	x.Kill()
+	var sigErr *ScriptSignalError
+	if _, err := x.Wait(); !errors.As(err, &sigErr) || sigErr.Signal != syscall.SIGKILL {
+		t.Errorf("Expected a ScriptSignalError for SIGKILL, got %v", err)
+	}
+}
+
+func TestGracefulStop(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "cleaned")
+	cases := []struct {
+		name   string
+		body   string
+		grace  [2]time.Duration
+		stage  StopStage
+		signal os.Signal
+	}{
+		{
+			name: "Interrupt",
+			body: fmt.Sprintf(`import time
+try:
+    time.sleep(30)
+except KeyboardInterrupt:
+    open(%q, "w").close()
+`, marker),
+			grace: [2]time.Duration{5 * time.Second, 5 * time.Second},
+			stage: StopInterrupt,
+		},
+		{
+			name:   "Terminate",
+			body:   "import signal, time\nsignal.signal(signal.SIGINT, signal.SIG_IGN)\ntime.sleep(30)\n",
+			grace:  [2]time.Duration{200 * time.Millisecond, 5 * time.Second},
+			stage:  StopTerminate,
+			signal: syscall.SIGTERM,
+		},
+		{
+			name:   "Kill",
+			body:   "import signal, time\nsignal.signal(signal.SIGINT, signal.SIG_IGN)\nsignal.signal(signal.SIGTERM, signal.SIG_IGN)\ntime.sleep(30)\n",
+			grace:  [2]time.Duration{200 * time.Millisecond, 200 * time.Millisecond},
+			stage:  StopKill,
+			signal: syscall.SIGKILL,
+		},
+		{
+			name:   "KillImmediately",
+			body:   "import time\ntime.sleep(30)\n",
+			stage:  StopKill,
+			signal: syscall.SIGKILL,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			script := writeScript(t, "stoppable.py", tc.body)
+			start := time.Now()
+			res, err := NewExecutor().Run(context.Background(), script, nil,
+				WithTimeout(300*time.Millisecond), WithGracePeriods(tc.grace[0], tc.grace[1]))
+			if !errors.Is(err, ErrTimeout) {
+				t.Fatalf("Expected ErrTimeout, got %v", err)
+			}
+			if time.Since(start) > 5*time.Second {
+				t.Errorf("Stopping took %v", time.Since(start))
+			}
+			if res.StopStage != tc.stage || res.Signal != tc.signal {
+				t.Errorf("Expected stage %v and signal %v, got %v and %v", tc.stage, tc.signal, res.StopStage, res.Signal)
+			}
+		})
+	}
+	if !fileExists(marker) {
+		t.Error("Expected the interrupted script to clean up")
+	}
+}
+
+// waitGone waits for pid to disappear entirely, i.e. to be killed and
+// reaped.
+func waitGone(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(fmt.Sprintf("/proc/%d", pid)); os.IsNotExist(err) {
+			return
+		}
+		if time.Now().After(deadline) {
+			st, _ := readProcStat(pid)
+			t.Fatalf("Process %d is still present (state %c)", pid, st.state)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestLeftoverChildrenKilled(t *testing.T) {
+	script := writeScript(t, "abandon.py", `import subprocess, sys
+child = subprocess.Popen([sys.executable, "-c", "import time; time.sleep(30)"])
+print(child.pid)
+`)
+	start := time.Now()
+	res, err := NewExecutor().Run(context.Background(), script, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("Run waited for the abandoned child: %v", time.Since(start))
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(res.Stdout)))
+	// The child is reaped by init, so a zombie counts as stopped.
+	for st, err := readProcStat(pid); err == nil && st.state != 'Z'; st, err = readProcStat(pid) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("Abandoned child %d is still running", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSubreaper(t *testing.T) {
+	// The grandchild starts its own session, so killing the script's
+	// process group does not reach it; only the subreaper tracking does.
+	script := writeScript(t, "escape.py", `import subprocess, sys, time
+child = subprocess.Popen([sys.executable, "-c", "import time; time.sleep(30)"],
+                         start_new_session=True, stdout=subprocess.DEVNULL)
+print(child.pid, flush=True)
+time.sleep(0.3)
+`)
+	exe := NewExecutor(WithSubreaper())
+	res, err := exe.Run(context.Background(), script, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(res.Stdout)))
+	waitGone(t, pid)
+
+	// The launcher is the subreaper, not the current process.
+	var subreaper int32
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prGetChildSubreaper, uintptr(unsafe.Pointer(&subreaper)), 0); errno != 0 || subreaper != 0 {
+		t.Errorf("Expected the test process not to be a child subreaper, got %d (%v)", subreaper, errno)
+	}
+
+	// The launcher exits the way the script did.
+	res, _ = exe.Run(context.Background(), writeScript(t, "exit3.py", "import sys\nsys.exit(3)\n"), nil)
+	if res.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", res.ExitCode)
+	}
+	res, _ = exe.Run(context.Background(), writeScript(t, "term.py", "import os, signal\nos.kill(os.getpid(), signal.SIGTERM)\n"), nil)
+	if res.Signal != syscall.SIGTERM {
+		t.Errorf("Expected SIGTERM, got %v", res.Signal)
+	}
+
+	// Cancellation stops the script and its escaped child too.
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	script = writeScript(t, "escape_wait.py", fmt.Sprintf(`import subprocess, sys, time
+child = subprocess.Popen([sys.executable, "-c", "import time; time.sleep(30)"],
+                         start_new_session=True)
+with open(%q, "w") as f:
+    f.write(str(child.pid))
+time.sleep(30)
+`, pidFile))
+	ctx, cancel := context.WithCancel(context.Background())
+	x, err := exe.Start(ctx, script, nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	for !fileExists(pidFile) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if _, err := x.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the execution to be canceled, got %v", err)
+	}
+	data, _ := os.ReadFile(pidFile)
+	pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+	waitGone(t, pid)
+}
+
+// prGetChildSubreaper is PR_GET_CHILD_SUBREAPER from <linux/prctl.h>.
+const prGetChildSubreaper = 37
+
+func TestNetworkIsolation(t *testing.T) {
+	script := writeScript(t, "network.py", `import socket, sys
+server = socket.socket()
+server.bind(("127.0.0.1", 0))
+server.listen()
+socket.create_connection(server.getsockname()).close()
+print("loopback ok", flush=True)
+socket.create_connection(("1.1.1.1", 80), timeout=5)
+`)
+	res, err := NewExecutor(WithNetworkIsolation()).Run(context.Background(), script, nil)
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENOSPC) {
+		t.Skipf("User namespaces are not available: %v", err)
+	}
+	var exitErr *ScriptExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Expected a ScriptExitError, got %v", err)
+	}
+	if string(res.Stdout) != "loopback ok\n" {
+		t.Errorf("Expected loopback to work, stdout %q, stderr %q", res.Stdout, res.Stderr)
+	}
+	if res.Reason != ReasonNetworkBlocked || !strings.Contains(string(res.Stderr), "Network is unreachable") {
+		t.Errorf("Expected a blocked network, got reason %q, stderr %q", res.Reason, res.Stderr)
+	}
+
+	// Limits and isolation share the launcher.
+	res, err = NewExecutor(WithNetworkIsolation(), WithLimits(Limits{OpenFiles: 40})).Run(context.Background(),
+		writeScript(t, "both.py", "import resource\nprint(resource.getrlimit(resource.RLIMIT_NOFILE)[0])\n"), nil)
+	if err != nil || strings.TrimSpace(string(res.Stdout)) != "40" {
+		t.Errorf("Expected the limit to apply inside the namespace, got %q, %v", res.Stdout, err)
+	}
+}
+
+func TestCredential(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Switching users requires root")
+	}
+	python, err := exec.LookPath("/usr/bin/python3")
+	if err != nil {
+		t.Skip("A system Python readable by other users is required")
+	}
+	cred, err := LookupCredential("nobody")
+	if err != nil {
+		t.Skipf("No nobody user: %v", err)
+	}
+	script := writeScript(t, "whoami.py", `import os, socket
+server = socket.socket()
+server.bind(("127.0.0.1", 0))
+server.listen()
+socket.create_connection(server.getsockname()).close()
+print(os.getuid(), os.getgid(), os.getgroups())
+`)
+	for dir := filepath.Dir(script); dir != os.TempDir(); dir = filepath.Dir(dir) {
+		if err := os.Chmod(dir, 0o755); err != nil {
+			t.Fatalf("Failed to make %s accessible: %v", dir, err)
+		}
+	}
+	want := fmt.Sprintf("%d %d %v\n", cred.UID, cred.GID, strings.ReplaceAll(fmt.Sprint(cred.Groups), " ", ", "))
+	exe := NewExecutor(WithInterpreter(python), WithCredential(cred))
+
+	res, err := exe.Run(context.Background(), script, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if string(res.Stdout) != want {
+		t.Errorf("Expected %q, got %q", want, res.Stdout)
+	}
+
+	res, err = exe.Run(context.Background(), script, nil, WithNetworkIsolation())
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENOSPC) {
+		t.Skipf("User namespaces are not available: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Run with network isolation failed: %v", err)
+	}
+	if string(res.Stdout) != want {
+		t.Errorf("Expected the same ids inside the namespace, %q, got %q", want, res.Stdout)
+	}
+}
+
+func TestPTY(t *testing.T) {
+	script := writeScript(t, "tty.py", `import os, sys
+print(sys.stdin.isatty(), sys.stdout.isatty(), sys.stderr.isatty())
+print(tuple(os.get_terminal_size()))
+print(repr(sys.stdin.read()))
+print("\x1b[31mred\x1b[0m", file=sys.stderr)
+`)
+	var lines []string
+	exe := NewExecutor(WithLineHandler(func(stream Stream, line string) { lines = append(lines, line) }))
+
+	res, err := exe.Stream(context.Background(), script, nil, WithPTY(PTY{Rows: 40, Cols: 120}))
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	want := "True True True\n(120, 40)\n''\n\x1b[31mred\x1b[0m\n"
+	if string(res.Stdout) != want || len(res.Stderr) != 0 {
+		t.Errorf("Expected stdout %q and no stderr, got %q, %q", want, res.Stdout, res.Stderr)
+	}
+	if len(lines) != 4 || lines[3] != "\x1b[31mred\x1b[0m" {
+		t.Errorf("Expected the terminal output as lines, got %q", lines)
+	}
+
+	res, err = exe.Run(context.Background(), script, nil, WithPTY(PTY{StripANSI: true}), WithStdinBytes([]byte("input")))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want = "False True True\n(80, 24)\n'input'\nred\n"
+	if string(res.Stdout) != want {
+		t.Errorf("Expected stdout %q, got %q", want, res.Stdout)
+	}
+}
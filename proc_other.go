@@ -0,0 +1,72 @@
+//go:build !linux
+
+package pyexec
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup is a no-op on platforms without Linux process groups;
+// cancellation falls back to killing the direct child only.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcess delivers sig to the direct child only.
+func signalProcess(cmd *exec.Cmd, sig os.Signal) error {
+	return cmd.Process.Signal(sig)
+}
+
+// killProcessGroup is a no-op without process groups.
+func killProcessGroup(cmd *exec.Cmd) {}
+
+// descendantTracker is not available on this platform; trackDescendants
+// is never called because checkSubreaper fails.
+type descendantTracker struct{}
+
+func trackDescendants(root int) *descendantTracker { return nil }
+
+func (t *descendantTracker) finish() int { return 0 }
+
+// usageSampler is not available on this platform; sampleUsage returns nil.
+type usageSampler struct{}
+
+func sampleUsage(root int, start time.Time, interval time.Duration) *usageSampler { return nil }
+
+func (s *usageSampler) finish() *Usage { return nil }
+
+// checkSubreaper reports that child subreapers are Linux-only.
+func checkSubreaper() error {
+	return errors.New("child subreaper is only supported on Linux")
+}
+
+// Exit signals are not reported on this platform, so limits are never
+// recognized from them.
+var sigCPULimit, sigFileSizeLimit os.Signal
+
+// isolateNetwork reports that namespaces are Linux-only.
+func isolateNetwork(cmd *exec.Cmd) error {
+	return errors.New("network isolation is only supported on Linux")
+}
+
+func setCredential(cmd *exec.Cmd, cred *Credential) error {
+	return errors.New("running scripts as another user is only supported on Linux")
+}
+
+func openPTY(rows, cols uint16) (master, slave *os.File, err error) {
+	return nil, nil, errors.New("pseudo-terminals are only supported on Linux")
+}
+
+func setControllingTerminal(cmd *exec.Cmd) {}
+
+func isTerminalClosed(err error) bool { return false }
+
+// landlockABI reports that Landlock is not available.
+func landlockABI() int { return 0 }
+
+// exitSignal is not reported on this platform.
+func exitSignal(state *os.ProcessState) os.Signal { return nil }
+
+// maxRSS is not reported on this platform.
+func maxRSS(state *os.ProcessState) int64 { return 0 }
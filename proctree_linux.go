@@ -0,0 +1,218 @@
+package pyexec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// procStat is the part of /proc/<pid>/stat used to follow a process tree
+// and sample its resource usage.
+type procStat struct {
+	pid   int
+	ppid  int
+	pgid  int
+	state byte
+	// startTime is in clock ticks since boot; together with pid it
+	// identifies a process even if its pid is later reused.
+	startTime uint64
+	// cpuTicks is the CPU time, user and system, of the process and of
+	// the children it has waited for, in clock ticks.
+	cpuTicks uint64
+	threads  int
+	// rssPages is the resident set size in pages.
+	rssPages int64
+}
+
+// readProcStat reads the status of one process.
+func readProcStat(pid int) (procStat, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procStat{}, err
+	}
+	// The command name may contain spaces and parentheses, so fields are
+	// counted from the last ')'.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 || i+2 > len(data) {
+		return procStat{}, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	// fields[n] is field n+3 of proc(5), which counts from 1.
+	fields := strings.Fields(string(data[i+2:]))
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	st := procStat{pid: pid, state: fields[0][0]}
+	st.ppid, _ = strconv.Atoi(fields[1])
+	st.pgid, _ = strconv.Atoi(fields[2])
+	for _, f := range fields[11:15] { // utime, stime, cutime, cstime
+		ticks, _ := strconv.ParseUint(f, 10, 64)
+		st.cpuTicks += ticks
+	}
+	st.threads, _ = strconv.Atoi(fields[17])
+	st.startTime, _ = strconv.ParseUint(fields[19], 10, 64)
+	st.rssPages, _ = strconv.ParseInt(fields[21], 10, 64)
+	return st, nil
+}
+
+// listProcs reads the status of every process on the system. Processes
+// that exit while the list is read are skipped.
+func listProcs() ([]procStat, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	procs := make([]procStat, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if st, err := readProcStat(pid); err == nil {
+			procs = append(procs, st)
+		}
+	}
+	return procs, nil
+}
+
+// descendantsOf returns the processes of procs that are one of roots or
+// descend from one of them.
+func descendantsOf(roots []int, procs []procStat) []procStat {
+	children := make(map[int][]procStat)
+	byPid := make(map[int]procStat, len(procs))
+	for _, p := range procs {
+		children[p.ppid] = append(children[p.ppid], p)
+		byPid[p.pid] = p
+	}
+	var out []procStat
+	seen := make(map[int]bool)
+	queue := slices.Clone(roots)
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		if p, ok := byPid[pid]; ok {
+			out = append(out, p)
+		}
+		for _, child := range children[pid] {
+			queue = append(queue, child.pid)
+		}
+	}
+	return out
+}
+
+// trackInterval is how often a descendantTracker scans /proc.
+const trackInterval = 50 * time.Millisecond
+
+// reapTimeout bounds how long finish waits for killed descendants to go
+// away.
+const reapTimeout = 2 * time.Second
+
+// descendantTracker follows every descendant of a script run under the
+// supervising launcher by scanning /proc while it runs: the processes below
+// the launcher, which adopts those the script orphans, the members of its
+// process group, and processes seen before that have since been orphaned,
+// once the launcher itself is killed. It backs up the launcher, which
+// kills everything left behind when the script exits normally.
+type descendantTracker struct {
+	root int
+
+	mu    sync.Mutex
+	known map[int]uint64 // pid to start time
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// trackDescendants starts tracking the descendants of root.
+func trackDescendants(root int) *descendantTracker {
+	t := &descendantTracker{
+		root:  root,
+		known: make(map[int]uint64),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go t.poll()
+	return t
+}
+
+func (t *descendantTracker) poll() {
+	defer close(t.done)
+	ticker := time.NewTicker(trackInterval)
+	defer ticker.Stop()
+	for {
+		t.scan()
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan records the current descendants and returns those still running.
+func (t *descendantTracker) scan() []procStat {
+	procs, err := listProcs()
+	if err != nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	roots := []int{t.root}
+	for _, p := range procs {
+		if start, ok := t.known[p.pid]; (ok && start == p.startTime) || p.pgid == t.root {
+			roots = append(roots, p.pid)
+		}
+	}
+	var alive []procStat
+	for _, p := range descendantsOf(roots, procs) {
+		if p.pid == t.root {
+			// The launcher is waited for by exec.Cmd.
+			continue
+		}
+		t.known[p.pid] = p.startTime
+		if p.state != 'Z' {
+			alive = append(alive, p)
+		}
+	}
+	return alive
+}
+
+// finish stops tracking, kills every descendant still alive and waits for
+// them to be reaped by their parent or init. It returns how many were
+// killed. A nil tracker does nothing.
+func (t *descendantTracker) finish() int {
+	if t == nil {
+		return 0
+	}
+	close(t.stop)
+	<-t.done
+	alive := t.scan()
+	for _, p := range alive {
+		syscall.Kill(p.pid, syscall.SIGKILL)
+	}
+	deadline := time.Now().Add(reapTimeout)
+	for pending := alive; len(pending) > 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		var next []procStat
+		for _, p := range pending {
+			st, err := readProcStat(p.pid)
+			if err == nil && st.startTime == p.startTime && st.state != 'Z' {
+				next = append(next, p)
+			}
+		}
+		pending = next
+	}
+	return len(alive)
+}
+
+// checkSubreaper reports whether scripts can be run under a child
+// subreaper; they always can on Linux.
+func checkSubreaper() error { return nil }
@@ -0,0 +1,135 @@
+package pyexec
+
+import (
+	"io"
+	"os"
+)
+
+// PTY runs a script attached to a pseudo-terminal (Linux only), for
+// libraries that behave differently when isatty() is false, such as
+// progress bars and colored logging, and scripts that require a terminal;
+// see WithPTY. The script's stdout and stderr are both the terminal, so
+// everything it writes is captured and streamed as stdout. Its stdin is the
+// terminal too, at end of input, unless it is set with WithStdin,
+// WithStdinBytes or WithStdinPipe.
+type PTY struct {
+	// Rows and Cols are the window size; zero values default to 24 rows
+	// and 80 columns.
+	Rows, Cols uint16
+	// StripANSI removes ANSI escape sequences, such as colors and cursor
+	// movement, from the output before it is captured and streamed.
+	StripANSI bool
+}
+
+// Default terminal window size.
+const (
+	defaultPTYRows = 24
+	defaultPTYCols = 80
+)
+
+// size returns the window size to use.
+func (p *PTY) size() (rows, cols uint16) {
+	rows, cols = p.Rows, p.Cols
+	if rows == 0 {
+		rows = defaultPTYRows
+	}
+	if cols == 0 {
+		cols = defaultPTYCols
+	}
+	return rows, cols
+}
+
+// eofCharacter is the default VEOF character, ^D, which makes a read from
+// a terminal in canonical mode return end of file.
+const eofCharacter = "\x04"
+
+// ptyReader reads the master end of a pseudo-terminal, turning the error
+// that reports the terminal closed into io.EOF.
+type ptyReader struct {
+	f *os.File
+}
+
+func (r ptyReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	if err != nil && isTerminalClosed(err) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// ansiStripper is a writer that removes ANSI escape sequences from what is
+// written to it before passing it on. Sequences may be split across
+// writes.
+type ansiStripper struct {
+	w     io.Writer
+	state ansiState
+	buf   []byte
+}
+
+type ansiState int
+
+const (
+	ansiText ansiState = iota
+	ansiEscape
+	// ansiCSI is inside a control sequence, ESC [, which ends with a byte
+	// in the range 0x40-0x7e.
+	ansiCSI
+	// ansiString is inside a string such as an operating system command,
+	// ESC ], which ends with BEL or ESC \.
+	ansiString
+	ansiStringEscape
+)
+
+func newANSIStripper(w io.Writer) *ansiStripper {
+	return &ansiStripper{w: w}
+}
+
+func (s *ansiStripper) Write(p []byte) (int, error) {
+	s.buf = s.buf[:0]
+	for _, b := range p {
+		switch s.state {
+		case ansiText:
+			if b == 0x1b {
+				s.state = ansiEscape
+			} else {
+				s.buf = append(s.buf, b)
+			}
+		case ansiEscape:
+			switch b {
+			case '[':
+				s.state = ansiCSI
+			case ']', 'P', 'X', '^', '_':
+				s.state = ansiString
+			default:
+				// A two-byte sequence, or an intermediate byte of a
+				// longer one, whose final byte is dropped too.
+				if b < 0x20 || b > 0x2f {
+					s.state = ansiText
+				}
+			}
+		case ansiCSI:
+			if b >= 0x40 && b <= 0x7e {
+				s.state = ansiText
+			}
+		case ansiString:
+			switch b {
+			case 0x07:
+				s.state = ansiText
+			case 0x1b:
+				s.state = ansiStringEscape
+			}
+		case ansiStringEscape:
+			if b == '\\' {
+				s.state = ansiText
+			} else {
+				s.state = ansiString
+			}
+		}
+	}
+	if len(s.buf) > 0 {
+		if _, err := s.w.Write(s.buf); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,22 @@
+package pyexec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestANSIStripper(t *testing.T) {
+	input := "\x1b[1;31mError\x1b[0m: \x1b]0;title\x07done\x1b(B 50%\r\x1b[2K\x1b]8;;http://x\x1b\\link\n"
+	want := "Error: done 50%\rlink\n"
+	// Feed the input a byte at a time, so every sequence is split.
+	var out bytes.Buffer
+	s := newANSIStripper(&out)
+	for i := range len(input) {
+		if n, err := s.Write([]byte{input[i]}); n != 1 || err != nil {
+			t.Fatalf("Write returned %d, %v", n, err)
+		}
+	}
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
@@ -1,17 +1,13 @@
 package pyexec
 
 import (
-	"bufio"
-	"bytes"
-	"errors"
+	"context"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"sync"
 )
 
 // fileExists checks if a file exists and is not a directory.
@@ -26,7 +22,8 @@ func fileExists(filename string) bool {
 // findScript attempts to locate the specified Python script by its name.
 // It searches in environment variables, a configurable list of directories,
 // relative paths, near the executable, and near the caller's source file.
-func findScript(scriptName string) (string, error) {
+// scriptDirs are searched before the directories in PYEXEC_SCRIPT_DIRS.
+func findScript(scriptName string, scriptDirs []string) (string, error) {
 	// 1. Check specific environment variable (e.g., SCRIPTNAME_PATH)
 	envVarSpecific := strings.ToUpper(strings.ReplaceAll(scriptName, ".", "_")) + "_PATH"
 	if scriptPath := os.Getenv(envVarSpecific); scriptPath != "" && fileExists(scriptPath) {
@@ -37,19 +34,22 @@ func findScript(scriptName string) (string, error) {
 		return scriptPath, nil // Return original path if abs fails
 	}
 
-	// 2. Check directories specified in PYEXEC_SCRIPT_DIRS environment variable
+	// 2. Check directories configured on the Executor, then those specified
+	// in the PYEXEC_SCRIPT_DIRS environment variable
 	envVarDirs := "PYEXEC_SCRIPT_DIRS"
+	dirList := scriptDirs
 	if searchDirs := os.Getenv(envVarDirs); searchDirs != "" {
-		dirList := filepath.SplitList(searchDirs) // Handles OS-specific separator ( : or ; )
-		for _, dir := range dirList {
-			scriptPath := filepath.Join(dir, scriptName)
-			if fileExists(scriptPath) {
-				absPath, err := filepath.Abs(scriptPath)
-				if err == nil {
-					return absPath, nil
-				}
-				return scriptPath, nil // Return found path if abs fails
+		// Handles OS-specific separator ( : or ; )
+		dirList = append(dirList[:len(dirList):len(dirList)], filepath.SplitList(searchDirs)...)
+	}
+	for _, dir := range dirList {
+		scriptPath := filepath.Join(dir, scriptName)
+		if fileExists(scriptPath) {
+			absPath, err := filepath.Abs(scriptPath)
+			if err == nil {
+				return absPath, nil
 			}
+			return scriptPath, nil // Return found path if abs fails
 		}
 	}
 
@@ -93,7 +93,7 @@ func findScript(scriptName string) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("script '%s' not found in any of the expected locations (checked env %s, env %s, cwd, executable dir, caller dir)", scriptName, envVarSpecific, envVarDirs)
+	return "", fmt.Errorf("%w: '%s' is not in any of the expected locations (checked env %s, script dirs, env %s, cwd, executable dir, caller dir)", ErrScriptNotFound, scriptName, envVarSpecific, envVarDirs)
 }
 
 // getPythonCommand returns the appropriate Python command (python3 or python).
@@ -128,43 +128,19 @@ func getPythonCommand() string {
 // represented with an empty string value.
 // It returns the standard output of the script as bytes.
 func ExecutePythonScript(scriptName string, args []Arg) ([]byte, error) {
-	scriptPath, err := findScript(scriptName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find python script: %w", err)
-	}
-
-	pythonCmd := getPythonCommand()
-
-	// Prepare command arguments, adding -u for unbuffered output
-	cmdArgs := []string{"-u", scriptPath} // <--- Added "-u"
-	for _, arg := range args {
-		cmdArgs = append(cmdArgs, arg.Key)
-		if arg.Value != "" {
-			cmdArgs = append(cmdArgs, arg.Value)
-		}
-	}
+	return ExecutePythonScriptContext(context.Background(), scriptName, args)
+}
 
-	cmd := exec.Command(pythonCmd, cmdArgs...)
-	cmd.Dir = filepath.Dir(scriptPath)
-	GetZlog().Info().Str("cmd", cmd.String()).Msg("Executing command")
-	stdout, err := cmd.Output()
+// ExecutePythonScriptContext is like ExecutePythonScript but stops the script
+// when ctx is cancelled or its deadline expires. On Linux the script runs in
+// its own process group and the whole group is killed, so processes spawned
+// by the script do not outlive it.
+func ExecutePythonScriptContext(ctx context.Context, scriptName string, args []Arg) ([]byte, error) {
+	res, err := NewExecutor().Run(ctx, scriptName, args)
 	if err != nil {
-		var stderr string
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			stderr = string(exitErr.Stderr)
-		}
-
-		errMsg := fmt.Sprintf("python script '%s' (in dir %s) execution failed: %v", scriptName, cmd.Dir, err)
-		if stderr != "" {
-			errMsg += fmt.Sprintf("\nstderr: %s", stderr)
-		}
-		if len(stdout) > 0 {
-			errMsg += fmt.Sprintf("\nstdout: %s", string(stdout))
-		}
-		return nil, errors.New(errMsg)
+		return nil, err
 	}
-
-	return stdout, nil
+	return res.Stdout, nil
 }
 
 // ExecutePythonScriptRealtime runs a Python script in unbuffered mode,
@@ -173,72 +149,20 @@ func ExecutePythonScript(scriptName string, args []Arg) ([]byte, error) {
 // It streams the output directly to the Go program's stdout and stderr.
 // Returns the captured stdout and an error if the script fails to start or exits with a non-zero status.
 func ExecutePythonScriptRealtime(scriptName string, args []Arg) ([]byte, error) {
-	scriptPath, err := findScript(scriptName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find python script: %w", err)
-	}
-
-	pythonCmd := getPythonCommand()
-
-	// Prepare command arguments, adding -u for unbuffered output
-	cmdArgs := []string{"-u", scriptPath} // <--- Added "-u"
-	for _, arg := range args {
-		cmdArgs = append(cmdArgs, arg.Key)
-		if arg.Value != "" {
-			cmdArgs = append(cmdArgs, arg.Value)
-		}
-	}
-
-	cmd := exec.Command(pythonCmd, cmdArgs...)
-	cmd.Dir = filepath.Dir(scriptPath)
-	GetZlog().Info().Str("cmd", cmd.String()).Msg("Executing command")
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
-	}
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get stderr pipe: %w", err)
-	}
-
-	var stdoutBuf bytes.Buffer
-
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start python script '%s' in dir '%s': %w", scriptName, cmd.Dir, err)
-	}
-
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	go func() {
-		defer wg.Done()
-		tee := io.TeeReader(stdoutPipe, &stdoutBuf)
-		scanner := bufio.NewScanner(tee)
-		for scanner.Scan() {
-			fmt.Fprintln(os.Stdout, "[stdout]", scanner.Text()) // Now should print in real-time
-		}
-		if err := scanner.Err(); err != nil {
-			fmt.Fprintf(os.Stderr, "error reading stdout from %s: %v\n", scriptName, err)
-		}
-	}()
-
-	go func() {
-		defer wg.Done()
-		scanner := bufio.NewScanner(stderrPipe)
-		for scanner.Scan() {
-			fmt.Fprintln(os.Stderr, "[stderr]", scanner.Text()) // Stderr is often unbuffered anyway
-		}
-		if err := scanner.Err(); err != nil {
-			fmt.Fprintf(os.Stderr, "error reading stderr from %s: %v\n", scriptName, err)
-		}
-	}()
+	return ExecutePythonScriptRealtimeContext(context.Background(), scriptName, args)
+}
 
-	cmdErr := cmd.Wait()
-	wg.Wait()
+// ExecutePythonScriptRealtimeContext is like ExecutePythonScriptRealtime but
+// stops the script (and, on Linux, its whole process group) when ctx is done.
+func ExecutePythonScriptRealtimeContext(ctx context.Context, scriptName string, args []Arg) ([]byte, error) {
+	return resultStdout(NewExecutor().Stream(ctx, scriptName, args))
+}
 
-	if cmdErr != nil {
-		return stdoutBuf.Bytes(), fmt.Errorf("python script '%s' (in dir %s) exited with error: %w", scriptName, cmd.Dir, cmdErr)
+// resultStdout adapts Executor.Stream to the realtime functions, which
+// return whatever stdout was captured even when the script fails.
+func resultStdout(res *Result, err error) ([]byte, error) {
+	if res == nil {
+		return nil, err
 	}
-
-	return stdoutBuf.Bytes(), nil
+	return res.Stdout, err
 }
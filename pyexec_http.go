@@ -1,6 +1,9 @@
 package pyexec
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -26,7 +29,7 @@ func GetZlog() *zerolog.Logger {
 	return zlog
 }
 
-func handleExecutionRequest(w http.ResponseWriter, r *http.Request, f func(scriptName string, args []Arg) ([]byte, error)) {
+func handleExecutionRequest(w http.ResponseWriter, r *http.Request, f func(ctx context.Context, scriptName string, args []Arg) ([]byte, error)) {
 	GetZlog().Info().Str("addr", r.RemoteAddr).Str("method", r.Method).Str("host", r.Host).Str("uri", r.RequestURI).Str("func", runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()).Msg("handleExecutionRequest")
 	start := time.Now()
 	defer func() {
@@ -81,23 +84,60 @@ func handleExecutionRequest(w http.ResponseWriter, r *http.Request, f func(scrip
 		args = make([]Arg, 0)
 	}
 
-	// Execute the script
-	output, err := f(scriptName, args)
+	// Execute the script; a client that disconnects cancels the request
+	// context, which stops the script.
+	output, err := f(r.Context(), scriptName, args)
 	if err != nil {
-		zlog.Error().Str("url", r.URL.Path).Str("error", err.Error()).Msg("Failed to execute script")
-		rest.ErrInternalServer(w, fmt.Sprintf("Failed to execute script: %s", err.Error()))
+		status := executionErrorStatus(err)
+		zlog.Error().Str("url", r.URL.Path).Int("status", status).Str("error", err.Error()).Msg("Failed to execute script")
+		writeExecutionError(w, status, fmt.Sprintf("Failed to execute script: %s", err.Error()))
 		return
 	}
 
 	rest.MustWriteJSONBytes(w, output)
 }
 
+// statusClientClosedRequest is the non-standard status used when the client
+// went away before the script finished.
+const statusClientClosedRequest = 499
+
+// executionErrorStatus maps an execution error to an HTTP status code.
+func executionErrorStatus(err error) int {
+	var exitErr *ScriptExitError
+	switch {
+	case errors.Is(err, ErrScriptNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInterpreterNotFound), errors.Is(err, ErrConfinementUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrTimeout), errors.Is(err, ErrIdleTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	case errors.As(err, &exitErr):
+		return http.StatusBadGateway
+	default:
+		// Killed by a signal, or failed to start.
+		return http.StatusInternalServerError
+	}
+}
+
+// writeExecutionError writes msg as a JSON error response with the given status.
+func writeExecutionError(w http.ResponseWriter, status int, msg string) {
+	if status == http.StatusInternalServerError {
+		rest.ErrInternalServer(w, msg)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"status": http.StatusText(status), "message": msg})
+}
+
 // HandlePythonExecutionRequest is an HTTP handler that executes a Python script.
 // It expects the script name as the last part of the URL path (e.g., /execute/script.py)
 // and arguments as query parameters.
 // Example: GET /execute/my_script.py?--input=data.csv&--threshold=0.5
 func HandlePythonExecutionRequest(w http.ResponseWriter, r *http.Request) {
-	handleExecutionRequest(w, r, ExecutePythonScript)
+	handleExecutionRequest(w, r, ExecutePythonScriptContext)
 }
 
 // HandlePythonExecutionRequestWithUV is an HTTP handler that executes a Python script using uv.
@@ -105,5 +145,18 @@ func HandlePythonExecutionRequest(w http.ResponseWriter, r *http.Request) {
 // and arguments as query parameters.
 // Example: GET /execute/my_script.py?--input=data.csv&--threshold=0.5
 func HandlePythonExecutionRequestWithUV(w http.ResponseWriter, r *http.Request) {
-	handleExecutionRequest(w, r, ExecutePythonScriptWithUV)
+	handleExecutionRequest(w, r, ExecutePythonScriptWithUVContext)
+}
+
+// HandleExecutionRequest is an HTTP handler like HandlePythonExecutionRequest
+// that runs scripts with the executor's configuration, e.g. its backend,
+// timeout and resource limits, including options set per script.
+func (e *Executor) HandleExecutionRequest(w http.ResponseWriter, r *http.Request) {
+	handleExecutionRequest(w, r, e.runForStdout)
+}
+
+// runForStdout runs the script and returns its stdout, as the legacy
+// functions do.
+func (e *Executor) runForStdout(ctx context.Context, scriptName string, args []Arg) ([]byte, error) {
+	return resultStdout(e.Run(ctx, scriptName, args))
 }
@@ -0,0 +1,49 @@
+package pyexec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandlePythonExecutionRequest(t *testing.T) {
+	failing := writeScript(t, "failing.py", "import sys\nsys.exit(2)\n")
+	t.Setenv("FAILING_PY_PATH", failing)
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"Success", "/execute/test_script.py?--flag", http.StatusOK},
+		{"ScriptNotFound", "/execute/non_existent_script.py", http.StatusNotFound},
+		{"ScriptFailed", "/execute/failing.py", http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			HandlePythonExecutionRequest(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestExecutorHandleExecutionRequest(t *testing.T) {
+	script := writeScript(t, "limited.py", "import resource\nprint(resource.getrlimit(resource.RLIMIT_NOFILE)[0])\n")
+	e := NewExecutor(
+		WithScriptDirs(filepath.Dir(script)),
+		WithScriptOptions("limited.py", WithLimits(Limits{OpenFiles: 64})),
+	)
+	rec := httptest.NewRecorder()
+	e.HandleExecutionRequest(rec, httptest.NewRequest(http.MethodGet, "/execute/limited.py", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != "64" {
+		t.Errorf("Expected the per-script limit to apply, got %q", got)
+	}
+}
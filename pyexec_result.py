@@ -0,0 +1,31 @@
+"""Reports the structured result of a script run by pyexec.
+
+pyexec puts this module on the script's import path when it runs with
+WithResultPipe. The result travels over its own file descriptor, named by
+the PYEXEC_RESULT_FD environment variable, so stdout is free for logs:
+
+    import pyexec_result
+    pyexec_result.set_result({"rows": 42})
+
+The Go side returns it in Result.Payload.
+"""
+import json
+import os
+
+ENV_VAR = "PYEXEC_RESULT_FD"
+
+_reported = False
+
+
+def set_result(value):
+    """Sends value, encoded as JSON, as the result. It can be called once."""
+    global _reported
+    if _reported:
+        raise RuntimeError("pyexec result already reported")
+    fd = os.environ.get(ENV_VAR)
+    if fd is None:
+        raise RuntimeError("%s is not set; run the script with pyexec's WithResultPipe" % ENV_VAR)
+    data = json.dumps(value).encode()
+    with os.fdopen(int(fd), "wb") as f:
+        f.write(data)
+    _reported = True
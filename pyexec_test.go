@@ -1,10 +1,15 @@
 package pyexec
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestExecutePythonScript(t *testing.T) {
@@ -53,10 +58,41 @@ func TestExecutePythonScript(t *testing.T) {
 		if err == nil {
 			t.Fatal("Expected an error when script is not found, but got nil")
 		}
-		if !strings.Contains(err.Error(), "not found") {
-			t.Errorf("Expected error message to contain 'not found', but got: %v", err)
+		if !errors.Is(err, ErrScriptNotFound) {
+			t.Errorf("Expected error to match ErrScriptNotFound, but got: %v", err)
 		}
 	})
 
 	// Add more test cases if needed, e.g., script execution error
 }
+
+// writeScript writes a throwaway Python script into a temporary directory
+// and returns its absolute path, which findScript accepts as a script name.
+func writeScript(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("Failed to write script %s: %v", name, err)
+	}
+	return path
+}
+
+func TestExecutePythonScriptContext(t *testing.T) {
+	t.Run("DeadlineStopsScript", func(t *testing.T) {
+		script := writeScript(t, "sleepy.py", "import time\ntime.sleep(30)\n")
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := ExecutePythonScriptContext(ctx, script, nil)
+		if err == nil {
+			t.Fatal("Expected an error when the deadline expires, but got nil")
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected error to wrap context.DeadlineExceeded, but got: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("Script was not stopped promptly, took %v", elapsed)
+		}
+	})
+}
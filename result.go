@@ -0,0 +1,201 @@
+package pyexec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Result describes a finished script execution.
+type Result struct {
+	// Stdout and Stderr hold what was captured from each stream. They are
+	// nil if the stream was spilled to disk; use OpenStdout and OpenStderr
+	// to read the output either way.
+	Stdout []byte
+	Stderr []byte
+	// StdoutTruncated and StderrTruncated report that output beyond the
+	// WithOutputLimit cap was dropped.
+	StdoutTruncated bool
+	StderrTruncated bool
+	// ExitCode is the script's exit status, or -1 if it was terminated by
+	// a signal or never ran to completion.
+	ExitCode int
+	// Signal is the signal that terminated the script, if any.
+	Signal os.Signal
+	// StopStage is the last step of the graceful stop sequence reached
+	// before the script exited, or StopNone if it was never asked to stop.
+	StopStage StopStage
+	// Reason explains why a failed script stopped when it was not simply
+	// its own exit status: a timeout, a cancellation or an exceeded limit.
+	Reason TerminationReason
+	// Duration is the wall-clock time from start to exit.
+	Duration time.Duration
+	// UserTime and SystemTime are the CPU time the process spent in user
+	// and kernel mode.
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// MaxRSS is the peak resident set size of the process in bytes, where
+	// the platform reports it.
+	MaxRSS int64
+	// Usage is the resource usage sampled while the script ran, with
+	// WithUsageSampling.
+	Usage *Usage
+	// Payload is the structured result of the execution, as JSON, when the
+	// script reports one separately from its output, e.g. the return value
+	// of the entry point called by a Pool.
+	Payload []byte
+	// Artifacts are the files the script wrote to the outputs directory of
+	// its Workspace, keyed by slash-separated path relative to it.
+	Artifacts map[string][]byte
+	// WorkDir is the Workspace directory of a failed execution, kept with
+	// KeepOnFailure for debugging.
+	WorkDir string
+
+	// Paths of output spilled to disk with WithSpill.
+	stdoutPath string
+	stderrPath string
+	// stderrTail is the end of stderr when Stderr does not hold all of it.
+	stderrTail []byte
+}
+
+// StopStage identifies a step of the sequence used to stop a script when
+// its context is done or its timeout expires: SIGINT, then SIGTERM, then
+// SIGKILL, each after the grace period set with WithGracePeriods.
+type StopStage int
+
+const (
+	// StopNone means the script exited without being asked to stop.
+	StopNone StopStage = iota
+	// StopInterrupt means the script exited after SIGINT, which Python
+	// raises as KeyboardInterrupt.
+	StopInterrupt
+	// StopTerminate means the script exited after SIGTERM.
+	StopTerminate
+	// StopKill means the script had to be killed with SIGKILL.
+	StopKill
+)
+
+// String returns the name of the stage.
+func (s StopStage) String() string {
+	switch s {
+	case StopNone:
+		return "none"
+	case StopInterrupt:
+		return "interrupt"
+	case StopTerminate:
+		return "terminate"
+	case StopKill:
+		return "kill"
+	default:
+		return fmt.Sprintf("StopStage(%d)", int(s))
+	}
+}
+
+// TerminationReason is why a script was stopped or failed, as reported in
+// Result.Reason.
+type TerminationReason string
+
+const (
+	// ReasonNone means the script exited on its own or succeeded.
+	ReasonNone TerminationReason = ""
+	// ReasonTimeout means the script was stopped because its deadline
+	// expired.
+	ReasonTimeout TerminationReason = "timeout"
+	// ReasonCanceled means the script was stopped because its context was
+	// cancelled.
+	ReasonCanceled TerminationReason = "canceled"
+	// ReasonCPULimit means the script used up its CPU time limit.
+	ReasonCPULimit TerminationReason = "cpu_limit"
+	// ReasonMemoryLimit means an allocation failed because of the memory
+	// limit.
+	ReasonMemoryLimit TerminationReason = "memory_limit"
+	// ReasonOpenFilesLimit means the script ran out of file descriptors.
+	ReasonOpenFilesLimit TerminationReason = "open_files_limit"
+	// ReasonProcessLimit means the script could not start a process
+	// because of the process limit.
+	ReasonProcessLimit TerminationReason = "process_limit"
+	// ReasonFileSizeLimit means the script wrote past the file size limit.
+	ReasonFileSizeLimit TerminationReason = "file_size_limit"
+	// ReasonNetworkBlocked means the script failed trying to reach the
+	// network while running with WithNetworkIsolation.
+	ReasonNetworkBlocked TerminationReason = "network_blocked"
+	// ReasonIdleTimeout means the script was stopped for producing no
+	// output for longer than its WithIdleTimeout.
+	ReasonIdleTimeout TerminationReason = "idle_timeout"
+)
+
+// Success reports whether the script exited with status 0.
+func (r *Result) Success() bool {
+	return r.ExitCode == 0 && r.Signal == nil
+}
+
+// OpenStdout returns a reader over the captured stdout, whether it is held
+// in memory or was spilled to disk.
+func (r *Result) OpenStdout() (io.ReadCloser, error) {
+	return openCaptured(r.Stdout, r.stdoutPath)
+}
+
+// OpenStderr returns a reader over the captured stderr, whether it is held
+// in memory or was spilled to disk.
+func (r *Result) OpenStderr() (io.ReadCloser, error) {
+	return openCaptured(r.Stderr, r.stderrPath)
+}
+
+// Close removes any files that output was spilled to. It is a no-op if
+// nothing was spilled.
+func (r *Result) Close() error {
+	var errs []error
+	for _, path := range []*string{&r.stdoutPath, &r.stderrPath} {
+		if *path != "" {
+			if err := os.Remove(*path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			*path = ""
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newResult builds a Result from a command that has been waited for.
+func newResult(cmd *exec.Cmd, start time.Time, stdout, stderr *capture) *Result {
+	res := capturedResult(start, stdout, stderr)
+	state := cmd.ProcessState
+	if state == nil {
+		return res
+	}
+	res.ExitCode = state.ExitCode()
+	res.Signal = exitSignal(state)
+	res.UserTime = state.UserTime()
+	res.SystemTime = state.SystemTime()
+	res.MaxRSS = maxRSS(state)
+	return res
+}
+
+// capturedResult builds a Result holding the output captured by stdout and
+// stderr, which it finishes, with an exit code of -1 until the caller sets
+// the exit status. Pool and fork server runs report theirs themselves.
+func capturedResult(start time.Time, stdout, stderr *capture) *Result {
+	res := &Result{
+		ExitCode:        -1,
+		Duration:        time.Since(start),
+		StdoutTruncated: stdout.truncated,
+		StderrTruncated: stderr.truncated,
+	}
+	res.Stdout, res.stdoutPath = stdout.finish()
+	res.Stderr, res.stderrPath = stderr.finish()
+	if stderr.truncated || res.stderrPath != "" {
+		res.stderrTail = stderr.tail
+	}
+	return res
+}
+
+// stderrContains reports whether the script printed s to stderr, as far as
+// it was captured: in Stderr or, if that does not hold all of it, in the
+// end of the stream, where Python prints the error that stopped a script.
+func (r *Result) stderrContains(s string) bool {
+	return bytes.Contains(r.Stderr, []byte(s)) || bytes.Contains(r.stderrTail, []byte(s))
+}
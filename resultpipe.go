@@ -0,0 +1,40 @@
+package pyexec
+
+import (
+	_ "embed"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// resultHelperSource is the Python module scripts use to report their
+// result over the result pipe.
+//
+//go:embed pyexec_result.py
+var resultHelperSource []byte
+
+// resultFDEnv names the environment variable holding the result pipe's
+// file descriptor.
+const resultFDEnv = "PYEXEC_RESULT_FD"
+
+// resultHelper holds the pyexec_result module, which is put on the import
+// path of scripts run with WithResultPipe.
+var resultHelper = &helperDir{file: "pyexec_result.py", source: resultHelperSource}
+
+// resultPipeEnv returns the variables that tell a script with environment
+// env where to send its result: the descriptor number and an import path
+// that starts with the helper module's directory.
+func resultPipeEnv(env []string, fd int) ([]string, error) {
+	dir, err := resultHelper.dir()
+	if err != nil {
+		return nil, err
+	}
+	pythonPath := dir
+	// The last definition is the one exec passes on.
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, "PYTHONPATH="); ok && value != "" {
+			pythonPath = dir + string(os.PathListSeparator) + value
+		}
+	}
+	return []string{resultFDEnv + "=" + strconv.Itoa(fd), "PYTHONPATH=" + pythonPath}, nil
+}
@@ -0,0 +1,181 @@
+package pyexec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// jsonMode selects which part of stdout RunJSON decodes.
+type jsonMode int
+
+const (
+	// jsonWhole decodes all of stdout.
+	jsonWhole jsonMode = iota
+	// jsonMarkers decodes the text between the last pair of markers.
+	jsonMarkers
+	// jsonLast decodes the last JSON value that starts a line.
+	jsonLast
+)
+
+// WithJSONMarkers makes RunJSON decode only the text between begin and end,
+// which the script prints around its result, e.g.
+// print(begin + json.dumps(result) + end). If the markers appear more than
+// once, the last pair is used. Everything else on stdout is ignored.
+func WithJSONMarkers(begin, end string) Option {
+	return func(c *config) {
+		c.jsonMode = jsonMarkers
+		c.jsonBegin, c.jsonEnd = begin, end
+	}
+}
+
+// WithLastJSONValue makes RunJSON decode the last JSON value on stdout
+// that starts at the beginning of a line and ends at the end of one,
+// skipping any other output before or after it. The value may span
+// several lines, as pretty-printed JSON does; its inner lines must then be
+// indented. Indented lines never start a value.
+func WithLastJSONValue() Option {
+	return func(c *config) {
+		c.jsonMode = jsonLast
+	}
+}
+
+// DecodeError is returned by RunJSON when the script succeeded but its
+// output could not be decoded.
+type DecodeError struct {
+	Script string
+	// Stdout is the script's raw output.
+	Stdout []byte
+	// Payload is what the script sent on its result pipe, with
+	// WithResultPipe.
+	Payload []byte
+	// Err is the reason, such as a *json.SyntaxError.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	msg := fmt.Sprintf("failed to decode JSON output of python script '%s': %v", e.Script, e.Err)
+	if len(e.Payload) > 0 {
+		msg += fmt.Sprintf("\npayload: %s", e.Payload)
+	}
+	return msg + fmt.Sprintf("\nstdout: %s", e.Stdout)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// RunJSON runs the script with e, as Run does, and decodes its stdout into
+// a T. By default all of stdout must be one JSON value; WithJSONMarkers and
+// WithLastJSONValue tolerate other output around it. With WithResultPipe,
+// the result reported on the pipe is decoded instead. If the script fails,
+// its error is returned without decoding; if decoding fails, the error is
+// a *DecodeError. The Result is returned whenever the script ran.
+func RunJSON[T any](ctx context.Context, e *Executor, scriptName string, args []Arg, opts ...Option) (T, *Result, error) {
+	var v T
+	c := e.configFor(scriptName, opts)
+	res, err := c.run(ctx, scriptName, args, nil)
+	if err != nil {
+		return v, res, err
+	}
+	stdout, err := readStdout(res)
+	if err != nil {
+		return v, res, err
+	}
+	var payload []byte
+	switch {
+	case !c.resultPipe:
+		payload, err = c.jsonPayload(stdout)
+	case len(res.Payload) == 0:
+		err = errors.New("no result was reported on the result pipe")
+	default:
+		payload = res.Payload
+	}
+	if err == nil {
+		err = json.Unmarshal(payload, &v)
+	}
+	if err != nil {
+		return v, res, &DecodeError{Script: scriptName, Stdout: stdout, Payload: res.Payload, Err: err}
+	}
+	return v, res, nil
+}
+
+// readStdout returns the captured stdout, reading it back if it was
+// spilled to disk.
+func readStdout(res *Result) ([]byte, error) {
+	if res.Stdout != nil {
+		return res.Stdout, nil
+	}
+	r, err := res.OpenStdout()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spilled stdout: %w", err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// jsonPayload extracts the part of stdout to decode.
+func (c *config) jsonPayload(stdout []byte) ([]byte, error) {
+	switch c.jsonMode {
+	case jsonMarkers:
+		end := bytes.LastIndex(stdout, []byte(c.jsonEnd))
+		if end < 0 {
+			return nil, fmt.Errorf("end marker %q not found", c.jsonEnd)
+		}
+		begin := bytes.LastIndex(stdout[:end], []byte(c.jsonBegin))
+		if begin < 0 {
+			return nil, fmt.Errorf("begin marker %q not found before the end marker", c.jsonBegin)
+		}
+		return stdout[begin+len(c.jsonBegin) : end], nil
+	case jsonLast:
+		if value := lastJSONValue(stdout); value != nil {
+			return value, nil
+		}
+		return nil, errors.New("no JSON value found")
+	}
+	return stdout, nil
+}
+
+// lastJSONValue returns the last JSON value in data that starts at the
+// beginning of a line, not after spaces, and is followed only by spaces up
+// to the end of a line, or nil if there is none. Lines are tried from the
+// last one up, so a value printed over several lines is found as a whole as
+// long as its inner lines are indented, as pretty-printers do; those lines
+// are never taken for values of their own.
+func lastJSONValue(data []byte) []byte {
+	for lineEnd := len(data); lineEnd > 0; {
+		start := bytes.LastIndexByte(data[:lineEnd-1], '\n') + 1
+		if value := jsonValueAt(data, start); value != nil {
+			return value
+		}
+		lineEnd = start
+	}
+	return nil
+}
+
+// jsonValueAt returns the JSON value starting at data[start], if it is
+// followed only by spaces up to the end of its last line.
+func jsonValueAt(data []byte, start int) []byte {
+	if start >= len(data) {
+		return nil
+	}
+	switch data[start] {
+	case ' ', '\t', '\r', '\n':
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data[start:]))
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return nil
+	}
+	end := start + int(dec.InputOffset())
+	rest := data[end:]
+	if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+		rest = rest[:i]
+	}
+	if len(bytes.TrimSpace(rest)) != 0 {
+		return nil
+	}
+	return data[start:end]
+}
@@ -0,0 +1,144 @@
+package pyexec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunJSON(t *testing.T) {
+	type report struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	exe := NewExecutor()
+	want := report{Name: "a", Count: 2}
+
+	t.Run("Whole", func(t *testing.T) {
+		script := writeScript(t, "whole.py", `import json
+print(json.dumps({"name": "a", "count": 2}))
+`)
+		got, res, err := RunJSON[report](context.Background(), exe, script, nil)
+		if err != nil || got != want || res == nil {
+			t.Errorf("Expected %+v, got %+v, %v", want, got, err)
+		}
+	})
+
+	t.Run("Markers", func(t *testing.T) {
+		script := writeScript(t, "markers.py", `import json
+print("loading model...")
+print("<<<" + json.dumps({"name": "old", "count": 1}) + ">>>")
+print("<<<" + json.dumps({"name": "a", "count": 2}) + ">>> trailing noise")
+print("done")
+`)
+		got, _, err := RunJSON[report](context.Background(), exe, script, nil, WithJSONMarkers("<<<", ">>>"))
+		if err != nil || got != want {
+			t.Errorf("Expected %+v, got %+v, %v", want, got, err)
+		}
+	})
+
+	t.Run("LastValue", func(t *testing.T) {
+		script := writeScript(t, "last.py", `import json
+print("UserWarning: something is deprecated")
+print(json.dumps({"name": "first", "count": 0}))
+print(json.dumps({"name": "a", "count": 2, "rows": [[1, 2], [3, 4]]}, indent=2))
+print("finished in 0.1s")
+`)
+		got, _, err := RunJSON[report](context.Background(), exe, script, nil, WithLastJSONValue())
+		if err != nil || got != want {
+			t.Errorf("Expected %+v, got %+v, %v", want, got, err)
+		}
+	})
+
+	t.Run("DecodeError", func(t *testing.T) {
+		script := writeScript(t, "noisy.py", `print("warning: cache miss")
+print('{"name": "a", "count": 2}')
+`)
+		_, res, err := RunJSON[report](context.Background(), exe, script, nil)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) || string(decodeErr.Stdout) != string(res.Stdout) {
+			t.Fatalf("Expected a DecodeError with the raw output, got %v", err)
+		}
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) || !strings.Contains(err.Error(), "warning: cache miss") {
+			t.Errorf("Expected a wrapped syntax error mentioning the output, got %v", err)
+		}
+
+		_, _, err = RunJSON[report](context.Background(), exe, script, nil, WithJSONMarkers("<<<", ">>>"))
+		if !errors.As(err, &decodeErr) || !strings.Contains(err.Error(), "marker") {
+			t.Errorf("Expected a DecodeError for missing markers, got %v", err)
+		}
+	})
+
+	t.Run("ScriptError", func(t *testing.T) {
+		script := writeScript(t, "fails.py", "import sys\nsys.exit(2)\n")
+		_, res, err := RunJSON[report](context.Background(), exe, script, nil)
+		var exitErr *ScriptExitError
+		if !errors.As(err, &exitErr) || res == nil || res.ExitCode != 2 {
+			t.Errorf("Expected the script's ScriptExitError, got %v", err)
+		}
+	})
+}
+
+func TestLastJSONValue(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"noise\n[1, 2]\nmore noise\n", "[1, 2]"},
+		{"[\n  [1, 2],\n  [3, 4]\n]\n", "[\n  [1, 2],\n  [3, 4]\n]"},
+		{"1 warning generated\n", ""},
+		{`{"a": 1} {"b": 2}` + "\n", ""},
+		{"\"x\"\n42\n", "42"},
+		{"{\"ok\": true}\n  retrying\n    42\n  [done]\n", "{\"ok\": true}"},
+		{"[1, 2]\n\n    3\n", "[1, 2]"},
+	}
+	for _, tc := range cases {
+		if got := strings.TrimSpace(string(lastJSONValue([]byte(tc.in)))); got != tc.want {
+			t.Errorf("lastJSONValue(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestResultPipe(t *testing.T) {
+	script := writeScript(t, "reporter.py", `import os, sys
+print("starting", flush=True)
+if len(sys.argv) > 1:
+    import pyexec_result
+    pyexec_result.set_result({"name": "a", "count": int(sys.argv[1])})
+    try:
+        pyexec_result.set_result(None)
+    except RuntimeError as e:
+        print(e)
+print("fd", os.environ["PYEXEC_RESULT_FD"], "path", os.environ["PYTHONPATH"].endswith("extra"))
+`)
+	exe := NewExecutor(WithResultPipe(), WithEnv("PYTHONPATH=extra"))
+
+	res, err := exe.Run(context.Background(), script, []Arg{{Key: "2"}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if string(res.Payload) != `{"name": "a", "count": 2}` {
+		t.Errorf("Unexpected payload %q", res.Payload)
+	}
+	want := "starting\npyexec result already reported\nfd 3 path True\n"
+	if string(res.Stdout) != want {
+		t.Errorf("Expected stdout %q, got %q", want, res.Stdout)
+	}
+
+	type report struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	got, _, err := RunJSON[report](context.Background(), exe, script, []Arg{{Key: "3"}})
+	if err != nil || got != (report{Name: "a", Count: 3}) {
+		t.Errorf("Expected the payload to be decoded, got %+v, %v", got, err)
+	}
+
+	_, res, err = RunJSON[report](context.Background(), exe, script, nil)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || len(res.Payload) != 0 {
+		t.Errorf("Expected a DecodeError without a result, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package pyexec
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// shim is a long-lived Python process running one of the embedded helper
+// programs (pool_worker.py, forkserver.py). It talks to Go with framed
+// JSON on its stdin and stdout; anything else it prints goes to stderr.
+type shim struct {
+	cmd       *exec.Cmd
+	kill      context.CancelFunc
+	requests  io.WriteCloser
+	respFile  *os.File
+	responses *bufio.Reader
+	exited    chan struct{}
+	waitErr   error
+}
+
+// startShim runs helper with `python -u` and args in dir, and waits for its
+// ready frame. what describes the process in logs and errors.
+func startShim(ctx context.Context, c *config, what string, helper *helperDir, dir string, args ...string) (*shim, error) {
+	path, err := helper.filePath()
+	if err != nil {
+		return nil, err
+	}
+	procCtx, kill := context.WithCancel(context.Background())
+	// WithSubreaper applies to executions only.
+	shimCfg := c.clone()
+	shimCfg.subreaper = false
+	shimCfg.shimHelper = helper
+	cmd, err := shimCfg.pythonCommand(procCtx, dir, append([]string{"-u", path}, args...))
+	if err != nil {
+		kill()
+		return nil, err
+	}
+	requests, err := cmd.StdinPipe()
+	if err != nil {
+		kill()
+		return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
+	}
+	// Plain pipes rather than StdoutPipe: Wait must not close them before
+	// the last response has been read.
+	respR, respW, err := os.Pipe()
+	if err != nil {
+		kill()
+		return nil, err
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		kill()
+		respR.Close()
+		respW.Close()
+		return nil, err
+	}
+	cmd.Stdout = respW
+	cmd.Stderr = errW
+
+	logStart(c, cmd, "Starting "+what)
+	err = cmd.Start()
+	respW.Close()
+	errW.Close()
+	if err != nil {
+		kill()
+		respR.Close()
+		errR.Close()
+		return nil, fmt.Errorf("failed to start %s: %w", what, err)
+	}
+
+	s := &shim{
+		cmd:       cmd,
+		kill:      kill,
+		requests:  requests,
+		respFile:  respR,
+		responses: bufio.NewReader(respR),
+		exited:    make(chan struct{}),
+	}
+	// Output written outside of the protocol, such as warnings at import
+	// time or a crash traceback, goes to the stderr sink.
+	sink := newLineSink(c)
+	go func() {
+		lines := sink.writer(Stderr, c.maxLine)
+		io.Copy(lines, errR)
+		lines.Flush()
+		errR.Close()
+	}()
+	go func() {
+		s.waitErr = cmd.Wait()
+		close(s.exited)
+	}()
+
+	var ready struct {
+		Ready bool   `json:"ready"`
+		Error string `json:"error"`
+	}
+	if err := s.roundTrip(ctx, nil, &ready); err != nil {
+		s.stop()
+		return nil, fmt.Errorf("%s failed to start: %w", what, err)
+	}
+	if !ready.Ready {
+		s.stop()
+		return nil, fmt.Errorf("%s failed to load:\n%s", what, ready.Error)
+	}
+	return s, nil
+}
+
+// roundTrip sends req (if not nil) and reads one response frame into resp.
+// If ctx is done first, the process is killed.
+func (s *shim) roundTrip(ctx context.Context, req, resp any) error {
+	return s.exchange(ctx, req, func() error {
+		return readFrame(s.responses, resp)
+	})
+}
+
+// exchange sends req (if not nil) and then calls read to read the response
+// frames. If ctx is done first, the process is killed.
+func (s *shim) exchange(ctx context.Context, req any, read func() error) error {
+	errc := make(chan error, 1)
+	go func() {
+		if req != nil {
+			if err := writeFrame(s.requests, req); err != nil {
+				errc <- err
+				return
+			}
+		}
+		errc <- read()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		s.kill()
+		<-errc
+		return ctx.Err()
+	}
+}
+
+// shimStopGrace is how long a shim may take to exit after its request
+// pipe is closed before it is killed.
+const shimStopGrace = 5 * time.Second
+
+// stop shuts the process down and waits for it to exit.
+func (s *shim) stop() {
+	s.requests.Close()
+	select {
+	case <-s.exited:
+	case <-time.After(shimStopGrace):
+		s.kill()
+		<-s.exited
+	}
+	s.kill()
+	s.respFile.Close()
+}
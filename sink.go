@@ -0,0 +1,131 @@
+package pyexec
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// Stream identifies one of a script's output streams.
+type Stream int
+
+const (
+	Stdout Stream = iota
+	Stderr
+)
+
+// String returns "stdout" or "stderr".
+func (s Stream) String() string {
+	if s == Stderr {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// LineHandler receives each line a script writes while it is streamed,
+// without its trailing newline. Calls are serialized across both streams.
+type LineHandler func(stream Stream, line string)
+
+// lineSink delivers streamed lines either to a LineHandler or to one
+// writer per stream, decorated with the configured prefix and timestamp.
+type lineSink struct {
+	mu         sync.Mutex
+	handler    LineHandler
+	writers    [2]io.Writer
+	prefixes   [2]string
+	timeLayout string
+}
+
+func newLineSink(c *config) *lineSink {
+	return &lineSink{
+		handler:    c.lineHandler,
+		writers:    [2]io.Writer{c.stdout, c.stderr},
+		prefixes:   [2]string{c.stdoutPrefix, c.stderrPrefix},
+		timeLayout: c.timeLayout,
+	}
+}
+
+// writer returns an io.Writer that splits what the script writes to stream
+// into lines for emit. Call Flush on it once the stream is drained.
+func (s *lineSink) writer(stream Stream, maxLine int) *lineWriter {
+	return &lineWriter{
+		emit: func(line string) { s.emit(stream, line) },
+		max:  maxLine,
+	}
+}
+
+// emit delivers one line from stream.
+func (s *lineSink) emit(stream Stream, line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handler != nil {
+		s.handler(stream, line)
+		return
+	}
+	w := s.writers[stream]
+	if w == nil {
+		return
+	}
+	var buf []byte
+	if s.timeLayout != "" {
+		buf = time.Now().AppendFormat(buf, s.timeLayout)
+		buf = append(buf, ' ')
+	}
+	buf = append(buf, s.prefixes[stream]...)
+	buf = append(buf, line...)
+	buf = append(buf, '\n')
+	w.Write(buf)
+}
+
+// lineWriter is an io.Writer that splits everything written to it into
+// lines and hands each one, without its line ending, to emit. It keeps
+// no limit on line length unless max is positive, in which case longer
+// lines are emitted in max-byte pieces as they arrive.
+type lineWriter struct {
+	emit func(line string)
+	max  int
+	buf  []byte
+	// split records that part of the current line was already emitted.
+	split bool
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		seg := p
+		if i >= 0 {
+			seg = p[:i]
+		}
+		w.buf = append(w.buf, seg...)
+		for w.max > 0 && len(w.buf) >= w.max {
+			w.emit(string(w.buf[:w.max]))
+			w.buf = append(w.buf[:0], w.buf[w.max:]...)
+			w.split = true
+		}
+		if i < 0 {
+			break
+		}
+		p = p[i+1:]
+		w.emitLine()
+	}
+	return n, nil
+}
+
+// Flush emits a final line that was not terminated by a newline.
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.emitLine()
+	}
+}
+
+// emitLine emits the buffered rest of the current line, unless the line
+// was split into pieces that ended exactly at the newline.
+func (w *lineWriter) emitLine() {
+	if len(w.buf) > 0 || !w.split {
+		w.emit(string(bytes.TrimSuffix(w.buf, []byte{'\r'})))
+	}
+	w.buf = w.buf[:0]
+	w.split = false
+}
@@ -0,0 +1,66 @@
+package pyexec
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		max    int
+		writes []string
+		want   []string
+	}{
+		{"SplitAcrossWrites", 0, []string{"he", "llo\nwor", "ld\r\n"}, []string{"hello", "world"}},
+		{"UnterminatedLine", 0, []string{"a\nb"}, []string{"a", "b"}},
+		{"LongLine", 0, []string{strings.Repeat("x", 1<<20), "\n"}, []string{strings.Repeat("x", 1<<20)}},
+		{"EmptyLines", 0, []string{"\n\n"}, []string{"", ""}},
+		{"MaxLength", 3, []string{"abcdefg\nhi"}, []string{"abc", "def", "g", "hi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			w := &lineWriter{emit: func(line string) { got = append(got, line) }, max: tt.max}
+			for _, s := range tt.writes {
+				w.Write([]byte(s))
+			}
+			w.Flush()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStreamLongLines(t *testing.T) {
+	// A single 4MB line, far beyond bufio.Scanner's 64KB token limit,
+	// followed by more output that must not be lost.
+	script := writeScript(t, "long_line.py", "import sys\nsys.stdout.write('x' * (4 << 20) + '\\n')\nprint('after')\nsys.stderr.write('y' * (1 << 20))\n")
+	var lengths []int
+	var stderrChunks int
+	res, err := NewExecutor().Stream(context.Background(), script, nil, WithLineHandler(func(stream Stream, line string) {
+		if stream == Stdout {
+			lengths = append(lengths, len(line))
+		} else {
+			stderrChunks++
+		}
+	}), WithMaxLineLength(256<<10))
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if want := strings.Repeat("x", 4<<20) + "\nafter\n"; string(res.Stdout) != want {
+		t.Errorf("Captured stdout is incomplete: got %d bytes, want %d", len(res.Stdout), len(want))
+	}
+	if len(res.Stderr) != 1<<20 {
+		t.Errorf("Captured stderr is incomplete: got %d bytes", len(res.Stderr))
+	}
+	if len(lengths) != 17 || lengths[0] != 256<<10 || lengths[16] != len("after") {
+		t.Errorf("Unexpected stdout line pieces: %d pieces", len(lengths))
+	}
+	if stderrChunks != 4 {
+		t.Errorf("Expected 4 stderr pieces, got %d", stderrChunks)
+	}
+}
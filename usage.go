@@ -0,0 +1,47 @@
+package pyexec
+
+import "time"
+
+// Usage is the resource usage of a script's process tree sampled from
+// /proc while it ran; see WithUsageSampling. The process tree is the
+// script's process, for BackendUV uv, with its descendants and the members
+// of its process group. Processes that start and exit between two samples
+// are missed.
+type Usage struct {
+	// Samples is the timeline, one sample per interval.
+	Samples []UsageSample
+	// PeakRSS is the highest total resident set size of the tree in bytes.
+	PeakRSS int64
+	// PeakThreads is the highest total number of threads in the tree.
+	PeakThreads int
+	// ReadBytes and WriteBytes are the bytes the tree read from and wrote
+	// to storage, as of the sample where they were highest.
+	ReadBytes  int64
+	WriteBytes int64
+}
+
+// UsageSample is the resource usage of the process tree at one time.
+type UsageSample struct {
+	// Elapsed is the time since the script started.
+	Elapsed time.Duration
+	// CPUTime is the CPU time, user and system, used by the tree so far,
+	// including processes of the tree that have exited and been waited
+	// for by their parent.
+	CPUTime time.Duration
+	// RSS is the total resident set size in bytes.
+	RSS       int64
+	Threads   int
+	Processes int
+	// ReadBytes and WriteBytes are the storage I/O of the tree so far.
+	ReadBytes  int64
+	WriteBytes int64
+}
+
+// add records a sample.
+func (u *Usage) add(s UsageSample) {
+	u.Samples = append(u.Samples, s)
+	u.PeakRSS = max(u.PeakRSS, s.RSS)
+	u.PeakThreads = max(u.PeakThreads, s.Threads)
+	u.ReadBytes = max(u.ReadBytes, s.ReadBytes)
+	u.WriteBytes = max(u.WriteBytes, s.WriteBytes)
+}
@@ -0,0 +1,122 @@
+package pyexec
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc, which is 100 on
+// every Linux architecture Go supports.
+const clockTicks = 100
+
+// usageSampler samples the resource usage of a script's process tree at a
+// fixed interval until finish is called.
+type usageSampler struct {
+	root     int
+	start    time.Time
+	interval time.Duration
+	pageSize int64
+	usage    Usage
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// sampleUsage starts sampling the tree of root, which started at start.
+func sampleUsage(root int, start time.Time, interval time.Duration) *usageSampler {
+	s := &usageSampler{
+		root:     root,
+		start:    start,
+		interval: interval,
+		pageSize: int64(os.Getpagesize()),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *usageSampler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if sample, ok := s.sample(); ok {
+			s.usage.add(sample)
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sample measures the tree as it is now. It reports false if the tree is
+// gone.
+func (s *usageSampler) sample() (UsageSample, bool) {
+	procs, err := listProcs()
+	if err != nil {
+		return UsageSample{}, false
+	}
+	roots := []int{s.root}
+	for _, p := range procs {
+		if p.pgid == s.root {
+			roots = append(roots, p.pid)
+		}
+	}
+	tree := descendantsOf(roots, procs)
+	if len(tree) == 0 {
+		return UsageSample{}, false
+	}
+	sample := UsageSample{Elapsed: time.Since(s.start), Processes: len(tree)}
+	var ticks uint64
+	for _, p := range tree {
+		ticks += p.cpuTicks
+		sample.RSS += p.rssPages * s.pageSize
+		sample.Threads += p.threads
+		if read, write, err := readProcIO(p.pid); err == nil {
+			sample.ReadBytes += read
+			sample.WriteBytes += write
+		}
+	}
+	sample.CPUTime = time.Duration(ticks) * time.Second / clockTicks
+	return sample, true
+}
+
+// finish stops sampling and returns the usage recorded. A nil sampler
+// returns nil.
+func (s *usageSampler) finish() *Usage {
+	if s == nil {
+		return nil
+	}
+	close(s.stop)
+	<-s.done
+	return &s.usage
+}
+
+// readProcIO returns the storage I/O counters of a process, which include
+// those of the children it has waited for.
+func readProcIO(pid int) (read, write int64, err error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/io", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		name, value, ok := bytes.Cut(sc.Bytes(), []byte(": "))
+		if !ok {
+			continue
+		}
+		switch string(name) {
+		case "read_bytes":
+			read, _ = strconv.ParseInt(string(value), 10, 64)
+		case "write_bytes":
+			write, _ = strconv.ParseInt(string(value), 10, 64)
+		}
+	}
+	return read, write, nil
+}
@@ -0,0 +1,54 @@
+package pyexec
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUsageSampling(t *testing.T) {
+	script := writeScript(t, "hungry.py", `import os, subprocess, sys, threading, time
+child = subprocess.Popen([sys.executable, "-c", "import time; time.sleep(0.5)"])
+blocks = []
+for _ in range(8):
+    blocks.append(bytearray(16 << 20))
+    time.sleep(0.05)
+threading.Thread(target=time.sleep, args=(0.5,)).start()
+with open(os.path.join(os.environ["TMPDIR"], "data"), "wb") as f:
+    f.write(os.urandom(4 << 20))
+    f.flush()
+    os.fsync(f.fileno())
+child.wait()
+`)
+	res, err := NewExecutor().Run(context.Background(), script, nil,
+		WithUsageSampling(20*time.Millisecond), WithWorkspace(Workspace{}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	u := res.Usage
+	if u == nil || len(u.Samples) < 5 {
+		t.Fatalf("Expected a timeline of samples, got %+v", u)
+	}
+	if u.PeakRSS < 128<<20 {
+		t.Errorf("Expected a peak RSS of at least 128 MiB, got %d", u.PeakRSS)
+	}
+	if u.PeakThreads < 3 {
+		t.Errorf("Expected at least 3 threads at the peak, got %d", u.PeakThreads)
+	}
+	if u.WriteBytes < 4<<20 {
+		t.Errorf("Expected at least 4 MiB written, got %d", u.WriteBytes)
+	}
+	processes := 0
+	for i, s := range u.Samples {
+		processes = max(processes, s.Processes)
+		if i > 0 && s.Elapsed <= u.Samples[i-1].Elapsed {
+			t.Errorf("Expected increasing sample times, got %v after %v", s.Elapsed, u.Samples[i-1].Elapsed)
+		}
+	}
+	if processes < 2 {
+		t.Errorf("Expected the child process to be sampled, got at most %d processes", processes)
+	}
+	if last := u.Samples[len(u.Samples)-1]; last.CPUTime <= 0 {
+		t.Errorf("Expected CPU time to be reported, got %v", last.CPUTime)
+	}
+}
@@ -1,125 +1,63 @@
 package pyexec
 
 import (
-	"bufio"
-	"bytes"
-	"errors"
+	"context"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
 	"path/filepath"
-	"sync"
 )
 
+// ExecutePythonScriptWithUV runs a Python script through `uv run`.
+// It behaves like ExecutePythonScript otherwise.
 func ExecutePythonScriptWithUV(scriptName string, args []Arg) ([]byte, error) {
-	if err := EnsureUVInstalled(); err != nil {
-		return nil, fmt.Errorf("failed to ensure uv is installed: %w", err)
-	}
-	scriptPath, err := findScript(scriptName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find python script: %w", err)
-	}
-
-	cmdArgs := []string{"run", "--", "python", "-u", scriptPath} // <--- Added "-u"
-	for _, arg := range args {
-		cmdArgs = append(cmdArgs, arg.Key)
-		if arg.Value != "" {
-			cmdArgs = append(cmdArgs, arg.Value)
-		}
-	}
+	return ExecutePythonScriptWithUVContext(context.Background(), scriptName, args)
+}
 
-	cmd := exec.Command("uv", cmdArgs...)
-	cmd.Dir = filepath.Dir(scriptPath)
-	GetZlog().Info().Str("cmd", cmd.String()).Msg("Executing command")
-	stdout, err := cmd.Output()
+// ExecutePythonScriptWithUVContext is like ExecutePythonScriptWithUV but stops
+// the script (and, on Linux, its whole process group) when ctx is done.
+func ExecutePythonScriptWithUVContext(ctx context.Context, scriptName string, args []Arg) ([]byte, error) {
+	res, err := NewExecutor(WithBackend(BackendUV)).Run(ctx, scriptName, args)
 	if err != nil {
-		var stderr string
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			stderr = string(exitErr.Stderr)
-		}
-
-		errMsg := fmt.Sprintf("python script '%s' (in dir %s) execution failed: %v", scriptName, cmd.Dir, err)
-		if stderr != "" {
-			errMsg += fmt.Sprintf("\nstderr: %s", stderr)
-		}
-		if len(stdout) > 0 {
-			errMsg += fmt.Sprintf("\nstdout: %s", string(stdout))
-		}
-		return nil, errors.New(errMsg)
+		return nil, err
 	}
-
-	return stdout, nil
+	return res.Stdout, nil
 }
 
+// ExecutePythonScriptRealtimeWithUV runs a Python script through `uv run`
+// and streams its output in real-time like ExecutePythonScriptRealtime.
 func ExecutePythonScriptRealtimeWithUV(scriptName string, args []Arg) ([]byte, error) {
-	if err := EnsureUVInstalled(); err != nil {
-		return nil, fmt.Errorf("failed to ensure uv is installed: %w", err)
-	}
-	scriptPath, err := findScript(scriptName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find python script: %w", err)
-	}
-
-	cmdArgs := []string{"run", "--", "python", "-u", scriptPath} // <--- Added "-u"
-	for _, arg := range args {
-		cmdArgs = append(cmdArgs, arg.Key)
-		if arg.Value != "" {
-			cmdArgs = append(cmdArgs, arg.Value)
-		}
-	}
+	return ExecutePythonScriptRealtimeWithUVContext(context.Background(), scriptName, args)
+}
 
-	cmd := exec.Command("uv", cmdArgs...)
-	cmd.Dir = filepath.Dir(scriptPath)
+// ExecutePythonScriptRealtimeWithUVContext is like
+// ExecutePythonScriptRealtimeWithUV but stops the script (and, on Linux, its
+// whole process group) when ctx is done.
+func ExecutePythonScriptRealtimeWithUVContext(ctx context.Context, scriptName string, args []Arg) ([]byte, error) {
+	return resultStdout(NewExecutor(WithBackend(BackendUV)).Stream(ctx, scriptName, args))
+}
 
-	GetZlog().Info().Str("cmd", cmd.String()).Msg("Executing command")
-	stdoutPipe, err := cmd.StdoutPipe()
+// Prepare resolves and installs the uv environment of scriptName's project
+// ahead of time, by running `uv run` once outside of any isolation. This
+// is required before running scripts with WithNetworkIsolation, where uv
+// cannot download anything. It does nothing for BackendPython.
+func (e *Executor) Prepare(ctx context.Context, scriptName string) error {
+	c := e.configFor(scriptName, nil)
+	if c.backend != BackendUV {
+		return nil
+	}
+	c.isolateNetwork = false
+	c.limits = Limits{}
+	c.confinement = nil
+	scriptPath, err := findScript(scriptName, c.scriptDirs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+		return fmt.Errorf("failed to find python script: %w", err)
 	}
-	stderrPipe, err := cmd.StderrPipe()
+	cmd, err := c.pythonCommand(ctx, filepath.Dir(scriptPath), []string{"-c", "pass"})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get stderr pipe: %w", err)
-	}
-
-	var stdoutBuf bytes.Buffer
-
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start python script '%s' in dir '%s': %w", scriptName, cmd.Dir, err)
+		return err
 	}
-
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	go func() {
-		defer wg.Done()
-		tee := io.TeeReader(stdoutPipe, &stdoutBuf)
-		scanner := bufio.NewScanner(tee)
-		for scanner.Scan() {
-			fmt.Fprintln(os.Stdout, "[stdout]", scanner.Text()) // Now should print in real-time
-		}
-		if err := scanner.Err(); err != nil {
-			fmt.Fprintf(os.Stderr, "error reading stdout from %s: %v\n", scriptName, err)
-		}
-	}()
-
-	go func() {
-		defer wg.Done()
-		scanner := bufio.NewScanner(stderrPipe)
-		for scanner.Scan() {
-			fmt.Fprintln(os.Stderr, "[stderr]", scanner.Text()) // Stderr is often unbuffered anyway
-		}
-		if err := scanner.Err(); err != nil {
-			fmt.Fprintf(os.Stderr, "error reading stderr from %s: %v\n", scriptName, err)
-		}
-	}()
-
-	cmdErr := cmd.Wait()
-	wg.Wait()
-
-	if cmdErr != nil {
-		return stdoutBuf.Bytes(), fmt.Errorf("python script '%s' (in dir %s) exited with error: %w", scriptName, cmd.Dir, cmdErr)
+	GetZlog().Info().Str("cmd", cmd.String()).Msg("Preparing uv environment")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to prepare uv environment for python script '%s' in dir '%s': %w\n%s", scriptName, cmd.Dir, err, out)
 	}
-
-	return stdoutBuf.Bytes(), nil
+	return nil
 }
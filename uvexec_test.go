@@ -2,6 +2,7 @@ package pyexec
 
 import (
 	"encoding/json"
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
@@ -53,8 +54,8 @@ func TestExecutePythonScriptWithUV(t *testing.T) {
 		if err == nil {
 			t.Fatal("Expected an error when script is not found, but got nil")
 		}
-		if !strings.Contains(err.Error(), "not found") {
-			t.Errorf("Expected error message to contain 'not found', but got: %v", err)
+		if !errors.Is(err, ErrScriptNotFound) {
+			t.Errorf("Expected error to match ErrScriptNotFound, but got: %v", err)
 		}
 	})
 
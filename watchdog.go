@@ -0,0 +1,61 @@
+package pyexec
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// idleWatchdog stops a script that has not written any output for a
+// while, by cancelling its context with an ErrIdleTimeout cause.
+type idleWatchdog struct {
+	timeout time.Duration
+	cancel  context.CancelCauseFunc
+	// last is when output was last seen, in nanoseconds since the Unix
+	// epoch.
+	last atomic.Int64
+}
+
+// withIdleTimeout returns a context that is cancelled once the script
+// started under it has been silent for c.idleTimeout, and the watchdog
+// that must be told about output and started; both are unchanged and nil
+// if there is no idle timeout.
+func (c *config) withIdleTimeout(ctx context.Context) (context.Context, *idleWatchdog) {
+	if c.idleTimeout <= 0 {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &idleWatchdog{timeout: c.idleTimeout, cancel: cancel}
+	w.last.Store(time.Now().UnixNano())
+	return ctx, w
+}
+
+// Write records output; it never fails, so it can be added to the writers
+// output is copied to.
+func (w *idleWatchdog) Write(p []byte) (int, error) {
+	w.last.Store(time.Now().UnixNano())
+	return len(p), nil
+}
+
+// watch checks for silence until done is closed.
+func (w *idleWatchdog) watch(done <-chan struct{}) {
+	w.last.Store(time.Now().UnixNano())
+	timer := time.NewTimer(w.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-done:
+			w.cancel(nil)
+			return
+		case <-timer.C:
+		}
+		idle := time.Since(time.Unix(0, w.last.Load()))
+		if idle >= w.timeout {
+			GetZlog().Info().Dur("idle", idle).Msg("Stopping script that stopped producing output")
+			w.cancel(fmt.Errorf("%w: no output for %v", ErrIdleTimeout, w.timeout))
+			return
+		}
+		timer.Reset(w.timeout - idle)
+	}
+}
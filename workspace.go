@@ -0,0 +1,167 @@
+package pyexec
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Workspace runs each execution in a fresh directory of its own, so that
+// concurrent runs of a script do not clobber each other's files; see
+// WithWorkspace. The directory is the script's working directory, HOME and,
+// through its tmp subdirectory, TMPDIR. Files the script writes under the
+// outputs subdirectory, also passed to it as PYEXEC_OUTPUT_DIR, are
+// returned in Result.Artifacts.
+type Workspace struct {
+	// Inputs are files written into the directory before the script
+	// starts, keyed by path relative to it.
+	Inputs map[string][]byte
+	// KeepOnFailure keeps the directory when the execution fails, for
+	// debugging, and reports it in Result.WorkDir. It is up to the caller
+	// to remove it. Otherwise the directory is always removed.
+	KeepOnFailure bool
+}
+
+// Subdirectories of an execution directory.
+const (
+	workspaceTmp     = "tmp"
+	workspaceOutputs = "outputs"
+)
+
+// execDir is the directory created for one execution, holding its
+// temporary files and, with a Workspace, its working directory.
+type execDir struct {
+	path string
+}
+
+// newExecDir creates the directory for an execution and stages the
+// workspace's inputs into it, if there is a workspace. Everything is
+// handed to the user the script runs as.
+func (c *config) newExecDir() (*execDir, error) {
+	prefix := "pyexec-tmp-*"
+	if c.workspace != nil {
+		prefix = "pyexec-work-*"
+	}
+	path, err := os.MkdirTemp("", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create execution directory: %w", err)
+	}
+	d := &execDir{path: path}
+	if err := d.populate(c); err != nil {
+		d.remove()
+		return nil, err
+	}
+	return d, nil
+}
+
+// populate creates the subdirectories and input files of the directory.
+func (d *execDir) populate(c *config) error {
+	dirs := []string{workspaceTmp}
+	if c.workspace != nil {
+		dirs = append(dirs, workspaceOutputs)
+	}
+	for _, dir := range dirs {
+		if err := os.Mkdir(filepath.Join(d.path, dir), 0o700); err != nil {
+			return fmt.Errorf("failed to create execution directory: %w", err)
+		}
+	}
+	if c.workspace != nil {
+		for name, data := range c.workspace.Inputs {
+			if !filepath.IsLocal(name) {
+				return fmt.Errorf("invalid input file name %q: must be a relative path within the workspace", name)
+			}
+			path := filepath.Join(d.path, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return fmt.Errorf("failed to stage input file %q: %w", name, err)
+			}
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				return fmt.Errorf("failed to stage input file %q: %w", name, err)
+			}
+		}
+	}
+	if c.credential != nil {
+		uid, gid := int(c.credential.UID), int(c.credential.GID)
+		err := filepath.WalkDir(d.path, func(path string, _ fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			return os.Lchown(path, uid, gid)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to hand execution directory to uid %d: %w", uid, err)
+		}
+	}
+	return nil
+}
+
+// tmp returns the directory to use as TMPDIR.
+func (d *execDir) tmp() string {
+	return filepath.Join(d.path, workspaceTmp)
+}
+
+// outputs returns the directory whose files are collected as artifacts.
+func (d *execDir) outputs() string {
+	return filepath.Join(d.path, workspaceOutputs)
+}
+
+// artifacts reads the regular files under the outputs directory, keyed by
+// slash-separated path relative to it. It must only be called once the
+// script and the processes it started are gone, so nothing can swap a file
+// for a symbolic link while it is read; links are skipped, so a script
+// cannot make the caller read files it could not read itself.
+func (d *execDir) artifacts() (map[string][]byte, error) {
+	artifacts := make(map[string][]byte)
+	root := d.outputs()
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		artifacts[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if len(artifacts) == 0 {
+		artifacts = nil
+	}
+	return artifacts, err
+}
+
+// finishExecDir collects the artifacts of a finished execution into res
+// and removes its directory, or keeps it if the execution failed and the
+// workspace asks for that.
+func (c *config) finishExecDir(scriptName string, res *Result, err error) {
+	d := c.execDir
+	if d == nil {
+		return
+	}
+	if c.workspace != nil {
+		var collectErr error
+		if res.Artifacts, collectErr = d.artifacts(); collectErr != nil {
+			GetZlog().Warn().Err(collectErr).Str("script", scriptName).Msg("Failed to collect artifacts")
+		}
+		if err != nil && c.workspace.KeepOnFailure {
+			res.WorkDir = d.path
+			GetZlog().Info().Str("script", scriptName).Str("dir", d.path).Msg("Keeping workspace of failed execution")
+			return
+		}
+	}
+	d.remove()
+}
+
+// remove deletes the directory. It is a no-op on a nil execDir.
+func (d *execDir) remove() {
+	if d == nil {
+		return
+	}
+	if err := os.RemoveAll(d.path); err != nil {
+		GetZlog().Warn().Err(err).Str("dir", d.path).Msg("Failed to remove execution directory")
+	}
+}
@@ -0,0 +1,83 @@
+package pyexec
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWorkspace(t *testing.T) {
+	script := writeScript(t, "report.py", `import os, sys
+with open("input.txt") as f:
+    name = f.read()
+with open(os.path.join(os.environ["PYEXEC_OUTPUT_DIR"], "report.txt"), "w") as f:
+    f.write("hello " + name)
+os.makedirs("outputs/nested")
+with open("outputs/nested/home.txt", "w") as f:
+    f.write("home" if os.environ["HOME"] == os.getcwd() else "elsewhere")
+with open("scratch.txt", "w") as f:
+    f.write(name)
+print(os.getcwd())
+`)
+	exe := NewExecutor()
+
+	t.Run("Isolated", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for _, name := range []string{"a", "b", "c"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := exe.Run(context.Background(), script, nil,
+					WithWorkspace(Workspace{Inputs: map[string][]byte{"input.txt": []byte(name)}}))
+				if err != nil {
+					t.Errorf("Run failed: %v", err)
+					return
+				}
+				if got := string(res.Artifacts["report.txt"]); got != "hello "+name {
+					t.Errorf("Expected report %q, got %q", "hello "+name, got)
+				}
+				if got := string(res.Artifacts["nested/home.txt"]); got != "home" {
+					t.Errorf("Expected HOME to be the workspace, got %q", got)
+				}
+				if len(res.Artifacts) != 2 {
+					t.Errorf("Expected 2 artifacts, got %v", res.Artifacts)
+				}
+				if dir := strings.TrimSpace(string(res.Stdout)); dir == "" || exists(dir) {
+					t.Errorf("Expected workspace %q to be removed", dir)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("KeepOnFailure", func(t *testing.T) {
+		res, err := exe.Run(context.Background(), script, nil, WithWorkspace(Workspace{KeepOnFailure: true}))
+		if err == nil {
+			t.Fatal("Expected the script to fail without its input")
+		}
+		if res.WorkDir == "" || !exists(res.WorkDir) {
+			t.Fatalf("Expected the workspace to be kept, got %q", res.WorkDir)
+		}
+		os.RemoveAll(res.WorkDir)
+
+		res, err = exe.Run(context.Background(), script, nil, WithWorkspace(Workspace{}))
+		if err == nil || res.WorkDir != "" {
+			t.Errorf("Expected a failure without a kept workspace, got %q, %v", res.WorkDir, err)
+		}
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		_, err := exe.Run(context.Background(), script, nil,
+			WithWorkspace(Workspace{Inputs: map[string][]byte{"../escape.txt": nil}}))
+		if err == nil || !strings.Contains(err.Error(), "invalid input file name") {
+			t.Errorf("Expected an invalid input error, got %v", err)
+		}
+	})
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}